@@ -0,0 +1,218 @@
+package subscribe
+
+import (
+	"maps"
+	"strings"
+)
+
+/***********************
+ *    Alias Methods    *
+ ***********************/
+
+// EventRename renames an event in the global registry, its definition, and every subscriber
+// and group subscription, keeping pause times and rules. The old name becomes an alias of the new
+// one, so GetSubscribers still finds subscribers by the old name, and existing aliases of
+// the old name are pointed at the new one. Returns ErrEventNotFound if nothing uses the
+// old name, or ErrEventExists if anything already uses the new name. Nothing is changed
+// when an error is returned.
+func (s *Subscribe) EventRename(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lists := s.eventMapsLocked()
+	found := false
+
+	// Hold every map from the check to the rename, so neither name can be taken in between.
+	for _, events := range lists {
+		events.mu.Lock()
+	}
+
+	defer func() {
+		for _, events := range lists {
+			events.mu.Unlock()
+		}
+	}()
+
+	for _, events := range lists {
+		exists, err := events.canRenameLocked(oldName, newName)
+		if err != nil {
+			return err
+		}
+
+		found = found || exists
+	}
+
+	if !found {
+		return ErrEventNotFound
+	}
+
+	for _, events := range lists {
+		events.renameLocked(oldName, newName)
+	}
+
+	if def := s.definitionLocked(oldName); def != nil {
+		s.undefineLocked(def.Name)
+		def.Name = newName
+		s.defineLocked(def)
+	}
+
+	for alias, event := range s.Aliases {
+		if event == oldName {
+			s.setAliasLocked(alias, newName)
+		}
+	}
+
+	s.removeAliasLocked(newName)
+	s.setAliasLocked(oldName, newName)
+
+	return nil
+}
+
+// EventAlias makes alias resolve to event in GetSubscribers.
+// If event is itself an alias, the new alias points to its event.
+func (s *Subscribe) EventAlias(alias, event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setAliasLocked(alias, s.resolveEventLocked(event))
+}
+
+// EventAliasRemove deletes an alias. The event it points to is not affected.
+func (s *Subscribe) EventAliasRemove(alias string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeAliasLocked(alias)
+}
+
+// EventAliases returns a copy of the alias to event map.
+func (s *Subscribe) EventAliases() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := make(map[string]string, len(s.Aliases))
+	maps.Copy(aliases, s.Aliases)
+
+	return aliases
+}
+
+// ResolveEvent returns the event an alias points to, or the name itself if it is not an alias.
+func (s *Subscribe) ResolveEvent(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.resolveEventLocked(name)
+}
+
+// resolveEventLocked returns the event an alias points to. Call with mu held.
+func (s *Subscribe) resolveEventLocked(name string) string {
+	if event, ok := s.Aliases[name]; ok {
+		return event
+	}
+
+	if len(s.Aliases) == 0 || !s.caseFolding() {
+		return name
+	}
+
+	for alias, event := range s.Aliases {
+		if strings.EqualFold(alias, name) {
+			return event
+		}
+	}
+
+	return name
+}
+
+func (s *Subscribe) setAliasLocked(alias, event string) {
+	if s.Aliases == nil {
+		s.Aliases = make(map[string]string)
+	}
+
+	s.Aliases[alias] = event
+	s.emit(&Change{Op: OpAlias, Event: alias, To: event})
+}
+
+func (s *Subscribe) removeAliasLocked(alias string) {
+	if _, ok := s.Aliases[alias]; !ok {
+		return
+	}
+
+	delete(s.Aliases, alias)
+	s.emit(&Change{Op: OpAliasRemove, Event: alias})
+}
+
+// applyAlias replays an alias change.
+func (s *Subscribe) applyAlias(record *Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.Op == OpAlias {
+		s.setAliasLocked(record.Event, record.To)
+	} else {
+		s.removeAliasLocked(record.Event)
+	}
+}
+
+// eventMapsLocked returns the global, subscriber and group Events maps. Call with mu held.
+func (s *Subscribe) eventMapsLocked() []*Events {
+	list := make([]*Events, 0, 1+len(s.Subscribers)+len(s.Groups))
+
+	if s.Events != nil {
+		list = append(list, s.Events)
+	}
+
+	for _, sub := range s.Subscribers {
+		if sub != nil && sub.Events != nil {
+			list = append(list, sub.Events)
+		}
+	}
+
+	for _, name := range s.groupNamesLocked() {
+		list = append(list, s.Groups[name].Events)
+	}
+
+	return list
+}
+
+// canRenameLocked returns true if the event exists, and an error if the new name is taken.
+// Call with mu held.
+func (e *Events) canRenameLocked(oldName, newName string) (bool, error) {
+	oldKey := e.keyLocked(oldName)
+	if _, ok := e.Map[oldKey]; !ok {
+		return false, nil
+	}
+
+	if newKey := e.keyLocked(newName); newKey != oldKey {
+		if _, ok := e.Map[newKey]; ok {
+			return true, ErrEventExists
+		}
+	}
+
+	return true, nil
+}
+
+// rename moves an event's rules to a new name. Does nothing if the event does not exist.
+func (e *Events) rename(oldName, newName string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.renameLocked(oldName, newName)
+}
+
+// renameLocked works like rename. Call with mu held.
+func (e *Events) renameLocked(oldName, newName string) {
+	oldName = e.keyLocked(oldName)
+
+	rules, ok := e.Map[oldName]
+	if !ok {
+		return
+	}
+
+	delete(e.Map, oldName)
+	e.Map[newName] = rules
+	e.emitLocked(&Change{Op: OpRename, Event: oldName, To: newName})
+}
@@ -0,0 +1,137 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRename(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	pause := time.Now().Add(time.Hour).Round(0)
+
+	require.NoError(t, sub.Events.New("motion", &Rules{I: map[string]int{"a": 1}}))
+
+	paused := sub.CreateSub("paused", "api", false, false)
+	require.NoError(t, paused.Subscribe("motion"))
+	require.NoError(t, paused.Events.PauseUntil("motion", pause))
+
+	active := sub.CreateSub("active", "api", false, false)
+	require.NoError(t, active.Subscribe("motion"))
+	active.Events.RuleSetS("motion", "sound", "chime")
+
+	group := sub.CreateGroup("oncall")
+	require.NoError(t, group.Subscribe("motion"))
+
+	require.ErrorIs(t, sub.EventRename("missing", "other"), ErrEventNotFound)
+
+	require.NoError(t, active.Subscribe("movement"))
+	require.ErrorIs(t, sub.EventRename("motion", "movement"), ErrEventExists)
+	assertions.True(paused.Events.Exists("motion"), "nothing may change when the rename fails")
+	active.Events.Remove("movement")
+
+	require.NoError(t, sub.EventRename("motion", "movement"))
+	assertions.Equal([]string{"movement"}, sub.Events.Names())
+	assertions.Equal(1, sub.Events.Map["movement"].I["a"])
+	assertions.Equal(pause, paused.Events.PauseTime("movement"))
+	sound, _ := active.Events.RuleGetS("movement", "sound")
+	assertions.Equal("chime", sound)
+	assertions.True(group.Events.Exists("movement"))
+	assertions.False(group.Events.Exists("motion"))
+
+	assertions.Equal([]*Subscriber{active}, sub.GetSubscribers("motion"), "the old name must still resolve")
+	assertions.Equal([]*Subscriber{active}, sub.GetSubscribers("movement"))
+
+	require.NoError(t, sub.EventRename("movement", "activity"))
+	assertions.Equal(map[string]string{"motion": "activity", "movement": "activity"}, sub.EventAliases())
+	assertions.Equal("activity", sub.ResolveEvent("motion"))
+
+	sub.EventAlias("trigger", "motion")
+	assertions.Equal("activity", sub.ResolveEvent("trigger"), "aliases of aliases must point to the event")
+	sub.EventAliasRemove("trigger")
+	assertions.Equal("trigger", sub.ResolveEvent("trigger"))
+
+	sub.EventCaseFolding(true)
+	assertions.Equal("activity", sub.ResolveEvent("MOTION"))
+}
+
+// renameStore signals when the global registry's rename is written, after EventRename has
+// checked every subscription, and gives a racing change time to land before it continues.
+type renameStore struct {
+	*memStore
+	renamed chan struct{}
+}
+
+func (r *renameStore) Write(change *Change) error {
+	if change.Op == OpRename && change.Sub == nil && change.Group == "" {
+		close(r.renamed)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return r.memStore.Write(change)
+}
+
+func TestEventRenameRace(t *testing.T) {
+	t.Parallel()
+
+	store := &renameStore{memStore: new(memStore), renamed: make(chan struct{})}
+	sub, err := GetStoreDB(store)
+	require.NoError(t, err)
+	require.NoError(t, sub.Events.New("motion", nil))
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	user.Events.RuleSetI("motion", "count", 1)
+
+	var newErr error
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		<-store.renamed
+
+		newErr = user.Events.New("movement", nil)
+	}()
+
+	require.NoError(t, sub.EventRename("motion", "movement"))
+	<-done
+
+	require.ErrorIs(t, newErr, ErrEventExists, "a subscription must not be added while renaming")
+
+	count, _ := user.Events.RuleGetI("movement", "count")
+	assert.Equal(t, 1, count, "the renamed subscription must keep its rules")
+}
+
+func TestEventRenameJournal(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	require.NoError(t, sub.Events.New("motion", nil))
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, sub.EventRename("motion", "movement"))
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"movement"}, loaded.Events.Names())
+	assert.Equal(t, map[string]string{"motion": "movement"}, loaded.EventAliases())
+	assert.Len(t, loaded.GetSubscribers("motion"), 1)
+
+	require.NoError(t, loaded.StateFileSave())
+
+	saved, err := GetDB(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"motion": "movement"}, saved.EventAliases())
+}
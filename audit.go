@@ -0,0 +1,426 @@
+package subscribe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+/***********************
+ *    Audit Methods    *
+ ***********************/
+
+// DefaultAuditSize is the number of audit entries kept in memory when none is provided.
+const DefaultAuditSize = 1000
+
+// auditQueueSize is the number of entries waiting to be written to the audit file
+// before more are left out of it. See AuditDropped.
+const auditQueueSize = 256
+
+// Reason explains why a subscriber is not notified of an occurrence.
+type Reason string
+
+// Reasons a subscriber is not notified of an occurrence.
+const (
+	// ReasonIgnored means the subscriber is ignored.
+	ReasonIgnored Reason = "ignored"
+	// ReasonAPIDisabled means the subscriber's API is not in EnableAPIs.
+	ReasonAPIDisabled Reason = "apiDisabled"
+	// ReasonNotSubscribed means the subscriber has no subscription matching the event.
+	ReasonNotSubscribed Reason = "notSubscribed"
+	// ReasonPaused means the subscription is paused.
+	ReasonPaused Reason = "paused"
+	// ReasonFiltered means the subscription's minimum severity or filter rejected the occurrence.
+	ReasonFiltered Reason = "filtered"
+	// ReasonDigest means the occurrence was added to the subscriber's digest.
+	ReasonDigest Reason = "digest"
+)
+
+// Exclusion is a subscriber left out of a GetSubscribers result, and why.
+type Exclusion struct {
+	Subscriber SubscriberRef `json:"subscriber"`
+	Reason     Reason        `json:"reason"`
+}
+
+// AuditEntry records a single GetSubscribers (or similar) evaluation.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace,omitempty"`
+	Event     string    `json:"event"`
+	// Included are the subscribers returned, in order.
+	Included []*SubscriberRef `json:"included"`
+	// Excluded are the other subscribers. Subscribers included through a group are not listed.
+	Excluded []*Exclusion `json:"excluded"`
+}
+
+// AuditQuery selects audit entries. Empty fields match everything.
+type AuditQuery struct {
+	Event string
+	// Subscriber matches entries that included or excluded this subscriber.
+	Subscriber *Subscriber
+	Since      time.Time
+	Until      time.Time
+	// Limit returns only the newest entries.
+	Limit int
+}
+
+// auditLog is a ring buffer of audit entries, optionally appended to a file.
+type auditLog struct {
+	mu      sync.Mutex
+	entries []*AuditEntry
+	next    int
+	size    int
+	// queue sends entries to the goroutine writing the file. nil without a file, or once closed.
+	queue chan *AuditEntry
+	done  chan struct{}
+	// dropped counts entries left out of the file because the queue was full.
+	dropped uint64
+	// These belong to the goroutine writing the file while it runs.
+	path  string
+	file  *os.File
+	lines int
+	err   error
+}
+
+// AuditEnable starts recording every GetSubscribers, GetSubscribersFor, GetOccurrenceSubscribers
+// and Dispatch evaluation, keeping the newest size entries in memory. If path is not empty, entries
+// are also appended to that file as JSON lines, and the newest entries already in it are loaded.
+// The file is written in the background, and trimmed to the newest size entries when enabled and
+// whenever it reaches twice that; entries arriving faster than the file is written are left out
+// of it and counted by AuditDropped. Audit entries from every namespace are kept together in
+// the root database.
+func (s *Subscribe) AuditEnable(size int, path string) error {
+	if s.parent != nil {
+		return s.parent.AuditEnable(size, path)
+	}
+
+	if size <= 0 {
+		size = DefaultAuditSize
+	}
+
+	if err := s.AuditDisable(); err != nil {
+		return err
+	}
+
+	log := &auditLog{size: size}
+
+	if path != "" {
+		if err := log.open(path); err != nil {
+			return err
+		}
+
+		log.queue, log.done = make(chan *AuditEntry, auditQueueSize), make(chan struct{})
+		go log.writeFile(log.queue)
+	}
+
+	s.hookMu.Lock()
+	s.audit = log
+	s.hookMu.Unlock()
+
+	return nil
+}
+
+// AuditDisable stops recording, and closes the audit file once every entry is written.
+// The entries are discarded. Returns the first error writing the audit file, if there was one.
+func (s *Subscribe) AuditDisable() error {
+	if s.parent != nil {
+		return s.parent.AuditDisable()
+	}
+
+	s.hookMu.Lock()
+	log := s.audit
+	s.audit = nil
+	s.hookMu.Unlock()
+
+	if log == nil {
+		return nil
+	}
+
+	return log.close()
+}
+
+// AuditQuery returns the audit entries matching a query, oldest first.
+// Returns nil if auditing is not enabled. Do not modify the entries.
+func (s *Subscribe) AuditQuery(query *AuditQuery) []*AuditEntry {
+	root := s.root()
+
+	root.hookMu.RLock()
+	log := root.audit
+	root.hookMu.RUnlock()
+
+	if log == nil {
+		return nil
+	}
+
+	if query == nil {
+		query = &AuditQuery{}
+	}
+
+	matched := []*AuditEntry{}
+
+	for _, entry := range log.list() {
+		if query.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+
+	if query.Limit > 0 && len(matched) > query.Limit {
+		matched = matched[len(matched)-query.Limit:]
+	}
+
+	return matched
+}
+
+// AuditDropped returns the number of audit entries left out of the audit file because they arrived
+// faster than it was written. They are still kept in memory. The count restarts with AuditEnable.
+func (s *Subscribe) AuditDropped() uint64 {
+	root := s.root()
+
+	root.hookMu.RLock()
+	log := root.audit
+	root.hookMu.RUnlock()
+
+	if log == nil {
+		return 0
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return log.dropped
+}
+
+// auditing returns true if the audit log is enabled.
+func (s *Subscribe) auditing() bool {
+	root := s.root()
+
+	root.hookMu.RLock()
+	defer root.hookMu.RUnlock()
+
+	return root.audit != nil
+}
+
+// recordAudit adds an evaluation to the audit log.
+func (s *Subscribe) recordAudit(occurrence *Occurrence, included []*Subscriber, excluded []*Exclusion) {
+	root := s.root()
+
+	root.hookMu.RLock()
+	log := root.audit
+	root.hookMu.RUnlock()
+
+	if log == nil {
+		return
+	}
+
+	entry := &AuditEntry{
+		Time:      occurrence.Time,
+		Namespace: s.namespace,
+		Event:     occurrence.Event,
+		Included:  make([]*SubscriberRef, 0, len(included)),
+		Excluded:  make([]*Exclusion, 0, len(excluded)),
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	for _, sub := range included {
+		entry.Included = append(entry.Included, sub.ref())
+	}
+
+	for _, exclusion := range excluded {
+		if !slices.ContainsFunc(included, func(sub *Subscriber) bool { return sub.matches(&exclusion.Subscriber) }) {
+			entry.Excluded = append(entry.Excluded, exclusion)
+		}
+	}
+
+	log.add(entry)
+}
+
+func (q *AuditQuery) matches(entry *AuditEntry) bool {
+	if (q.Event != "" && entry.Event != q.Event) ||
+		(!q.Since.IsZero() && entry.Time.Before(q.Since)) ||
+		(!q.Until.IsZero() && entry.Time.After(q.Until)) {
+		return false
+	}
+
+	if q.Subscriber == nil {
+		return true
+	}
+
+	return slices.ContainsFunc(entry.Included, q.Subscriber.matches) ||
+		slices.ContainsFunc(entry.Excluded, func(exclusion *Exclusion) bool {
+			return q.Subscriber.matches(&exclusion.Subscriber)
+		})
+}
+
+// open loads the newest entries from an audit file, rewrites it with only those, and
+// opens it for appending.
+func (l *auditLog) open(path string) error {
+	entries, err := readAuditFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		l.insert(entry)
+	}
+
+	l.path = path
+
+	return l.rewrite(l.list())
+}
+
+// readAuditFile returns the entries in an audit file, oldest first.
+func readAuditFile(path string) ([]*AuditEntry, error) {
+	// #nosec G304 -- audit path is provided by the user.
+	buf, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed reading audit file: %w", err)
+	}
+
+	entries := []*AuditEntry{}
+
+	for line := range bytes.SplitSeq(buf, []byte("\n")) {
+		entry := &AuditEntry{}
+		// A torn or damaged line is dropped.
+		if json.Unmarshal(line, entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// rewrite replaces the audit file with entries, and opens it for appending.
+func (l *auditLog) rewrite(entries []*AuditEntry) error {
+	const auditMode = 0o600
+
+	if l.file != nil {
+		if err := l.file.Close(); err != nil {
+			return fmt.Errorf("closing audit file: %w", err)
+		}
+
+		l.file = nil
+	}
+
+	var out bytes.Buffer
+
+	for _, entry := range entries {
+		line, _ := json.Marshal(entry)
+		out.Write(append(line, '\n'))
+	}
+
+	if err := os.WriteFile(l.path, out.Bytes(), auditMode); err != nil {
+		return fmt.Errorf("failed writing audit file: %w", err)
+	}
+
+	// #nosec G304 -- audit path is provided by the user.
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, auditMode)
+	if err != nil {
+		return fmt.Errorf("failed opening audit file: %w", err)
+	}
+
+	l.file, l.lines = file, len(entries)
+
+	return nil
+}
+
+// add records an entry, and queues it for the audit file. It never waits for the file, because
+// callers hold the database lock; an entry that does not fit in the queue is counted as dropped.
+func (l *auditLog) add(entry *AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.insert(entry)
+
+	if l.queue == nil {
+		return
+	}
+
+	select {
+	case l.queue <- entry:
+	default:
+		l.dropped++
+	}
+}
+
+// writeFile appends queued entries to the audit file until the queue is closed.
+// The file is trimmed to the newest size entries when it reaches twice that.
+func (l *auditLog) writeFile(queue chan *AuditEntry) {
+	defer close(l.done)
+
+	for entry := range queue {
+		if l.err != nil {
+			continue
+		}
+
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = l.file.Write(append(line, '\n'))
+		}
+
+		if err != nil {
+			l.err = fmt.Errorf("writing audit file: %w", err)
+			continue
+		}
+
+		if l.lines++; l.lines < 2*l.size {
+			continue
+		}
+
+		entries, err := readAuditFile(l.path)
+		if err == nil {
+			err = l.rewrite(entries[max(0, len(entries)-l.size):])
+		}
+
+		l.err = err
+	}
+}
+
+// close stops queueing entries, waits for the queued ones to be written, and closes the file.
+// Returns the first error writing the file.
+func (l *auditLog) close() error {
+	l.mu.Lock()
+	queue := l.queue
+	l.queue = nil
+	l.mu.Unlock()
+
+	if queue == nil {
+		return nil
+	}
+
+	close(queue)
+	<-l.done
+
+	if l.file != nil {
+		if err := l.file.Close(); err != nil && l.err == nil {
+			l.err = fmt.Errorf("closing audit file: %w", err)
+		}
+	}
+
+	return l.err
+}
+
+// insert adds an entry to the ring buffer, replacing the oldest one when full.
+func (l *auditLog) insert(entry *AuditEntry) {
+	if len(l.entries) < l.size {
+		l.entries = append(l.entries, entry)
+		return
+	}
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % l.size
+}
+
+// list returns the entries, oldest first.
+func (l *auditLog) list() []*AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append(slices.Clone(l.entries[l.next:]), l.entries[:l.next]...)
+}
@@ -0,0 +1,149 @@
+package subscribe
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}, EnableAPIs: []string{"api"}}
+
+	included := sub.CreateSub("included", "api", false, false)
+	ignored := sub.CreateSub("ignored", "api", false, true)
+	disabled := sub.CreateSub("disabled", "other", false, false)
+	paused := sub.CreateSub("paused", "api", false, false)
+	missing := sub.CreateSub("missing", "api", false, false)
+	member := sub.CreateSub("member", "api", false, false)
+
+	for _, s := range []*Subscriber{included, ignored, disabled, paused} {
+		require.NoError(t, s.Subscribe("motion"))
+	}
+
+	require.NoError(t, paused.Events.Pause("motion", time.Hour))
+
+	group := sub.CreateGroup("oncall")
+	group.AddMember(member)
+	require.NoError(t, group.Subscribe("motion"))
+
+	sub.GetSubscribers("motion")
+	assertions.Nil(sub.AuditQuery(nil), "auditing is off by default")
+
+	require.NoError(t, sub.AuditEnable(2, ""))
+	assertions.Equal([]*Subscriber{included, member}, sub.GetSubscribers("motion"))
+
+	entries := sub.AuditQuery(nil)
+	require.Len(t, entries, 1)
+	assertions.Equal("motion", entries[0].Event)
+	assertions.Equal([]*SubscriberRef{included.ref(), member.ref()}, entries[0].Included)
+	assertions.Equal([]*Exclusion{
+		{Subscriber: *ignored.ref(), Reason: ReasonIgnored},
+		{Subscriber: *disabled.ref(), Reason: ReasonAPIDisabled},
+		{Subscriber: *paused.ref(), Reason: ReasonPaused},
+		{Subscriber: *missing.ref(), Reason: ReasonNotSubscribed},
+	}, entries[0].Excluded, "members included through a group must not be listed as excluded")
+
+	sub.GetSubscribers("door")
+	sub.GetSubscribers("window")
+
+	entries = sub.AuditQuery(nil)
+	require.Len(t, entries, 2, "the ring buffer must be bounded")
+	assertions.Equal("door", entries[0].Event)
+	assertions.Equal("window", entries[1].Event)
+
+	assertions.Len(sub.AuditQuery(&AuditQuery{Event: "door"}), 1)
+	assertions.Len(sub.AuditQuery(&AuditQuery{Limit: 1}), 1)
+	assertions.Empty(sub.AuditQuery(&AuditQuery{Since: time.Now().Add(time.Hour)}))
+	assertions.Len(sub.AuditQuery(&AuditQuery{Subscriber: missing}), 2)
+
+	require.NoError(t, sub.AuditDisable())
+	assertions.Nil(sub.AuditQuery(nil))
+}
+
+func TestAuditFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+
+	require.NoError(t, sub.AuditEnable(10, path))
+	sub.Namespace("tenant").GetSubscribers("motion")
+
+	for range 3 {
+		sub.GetSubscribers("motion")
+	}
+
+	require.NoError(t, sub.AuditDisable())
+	require.NoError(t, sub.AuditEnable(10, path))
+
+	entries := sub.AuditQuery(nil)
+	require.Len(t, entries, 4, "entries must be loaded from the file")
+	assert.Equal(t, "tenant", entries[0].Namespace)
+	assert.Equal(t, []*SubscriberRef{user.ref()}, entries[3].Included)
+
+	require.NoError(t, sub.AuditEnable(1, path))
+	require.NoError(t, sub.AuditEnable(10, path))
+	assert.Len(t, sub.AuditQuery(nil), 1, "the file must be trimmed to the size")
+	require.NoError(t, sub.AuditDisable())
+}
+
+func TestAuditFileBounded(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	require.NoError(t, sub.AuditEnable(3, path))
+
+	for range 100 {
+		sub.GetSubscribers("motion")
+	}
+
+	require.NoError(t, sub.AuditDisable())
+
+	buf, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := bytes.Count(buf, []byte("\n"))
+	assert.GreaterOrEqual(t, lines, 3, "the newest entries must be kept")
+	assert.Less(t, lines, 6, "the file must be trimmed when it reaches twice the size")
+}
+
+func TestAuditDropped(t *testing.T) {
+	t.Parallel()
+
+	// Nothing reads this queue, like a file writer stuck on a slow disk.
+	sub := &Subscribe{
+		Events: &Events{Map: make(map[string]*Rules)},
+		audit:  &auditLog{size: 10, queue: make(chan *AuditEntry, 1)},
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range 3 {
+			sub.GetSubscribers("motion")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a full audit queue must not block lookups")
+	}
+
+	assert.Equal(t, uint64(2), sub.AuditDropped())
+	assert.Len(t, sub.AuditQuery(nil), 3, "dropped entries must stay in memory")
+	assert.Zero(t, (&Subscribe{}).AuditDropped())
+}
@@ -0,0 +1,347 @@
+// Package boltstore persists golift.io/subscribe databases in a bbolt key/value file, for
+// single-binary deployments. Each subscriber and each event subscription of the database
+// and its groups is its own key, and every change is committed in its own transaction.
+// Everything else, like groups, definitions and digests, is kept as JSON and the changes
+// to it are recorded until the next save, or until FoldChanges of them are folded into it.
+// Importing this package makes GetDB open files ending in .db with a Store:
+//
+//	import _ "golift.io/subscribe/boltstore"
+//
+//	db, err := subscribe.GetDB("/var/lib/app/subscribers.db")
+//	defer db.Close()
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golift.io/subscribe"
+)
+
+// Extension is the file extension GetDB opens with a Store.
+const Extension = ".db"
+
+// DefaultTimeout is how long Open waits for another process to close the file.
+const DefaultTimeout = 5 * time.Second
+
+// ErrBadKey is returned when a key in the file is not in the expected format.
+var ErrBadKey = errors.New("invalid key")
+
+// Buckets, and the key of the JSON state in the state bucket.
+var (
+	bucketSubscribers = []byte("subscribers") // namespace, sequence: subscriber JSON.
+	bucketIndex       = []byte("index")       // namespace, api, identity: key in bucketSubscribers.
+	bucketEvents      = []byte("events")      // namespace, group, event: rules JSON.
+	bucketChanges     = []byte("changes")     // sequence: change JSON.
+	bucketState       = []byte("state")
+	keyState          = []byte("state")
+)
+
+// sep separates the parts of a key.
+const sep = "\x00"
+
+// FoldChanges is the number of recorded changes Write folds into the saved JSON,
+// so the changes bucket does not grow without bound between saves.
+const FoldChanges = 1000
+
+// Store is a subscribe.Store backed by a bbolt file.
+type Store struct {
+	db *bolt.DB
+	// foldAt is the number of recorded changes folded into the saved JSON.
+	foldAt int
+}
+
+func init() {
+	subscribe.RegisterStore(Extension, func(path string) (subscribe.Store, error) { return Open(path) })
+}
+
+// Open opens, or creates, a bbolt file. Waits up to DefaultTimeout if another process has it open.
+func Open(path string) (*Store, error) {
+	const fileMode = 0o600
+
+	db, err := bolt.Open(path, fileMode, &bolt.Options{Timeout: DefaultTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketSubscribers, bucketIndex, bucketEvents, bucketChanges, bucketState} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db, foldAt: FoldChanges}, nil
+}
+
+// Close closes the file.
+func (s *Store) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("closing database: %w", err)
+	}
+
+	return nil
+}
+
+// Import replaces the contents of the store with a state file written by StateFileSave.
+// Call StateFileLoad on databases already using the store to pick it up.
+func (s *Store) Import(ctx context.Context, stateFile string) error {
+	return subscribe.StoreImport(ctx, s, stateFile)
+}
+
+// Load returns the database: the JSON written by Save, with the recorded changes replayed,
+// then the subscribers and subscriptions from their keys.
+func (s *Store) Load(ctx context.Context) (*subscribe.Subscribe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var db *subscribe.Subscribe
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		if db, err = loadState(tx); err != nil {
+			return err
+		}
+
+		if err = loadSubscribers(tx, db); err != nil {
+			return err
+		}
+
+		return loadEvents(tx, db)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// loadState returns the JSON written by Save, with the recorded changes replayed.
+func loadState(tx *bolt.Tx) (*subscribe.Subscribe, error) {
+	db := new(subscribe.Subscribe)
+
+	if state := tx.Bucket(bucketState).Get(keyState); state != nil {
+		if err := json.Unmarshal(state, db); err != nil {
+			return nil, fmt.Errorf("reading state: %w", err)
+		}
+	}
+
+	changes := []*subscribe.Change{}
+
+	err := tx.Bucket(bucketChanges).ForEach(func(_, record []byte) error {
+		change := new(subscribe.Change)
+		changes = append(changes, change)
+
+		return json.Unmarshal(record, change)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+
+	db.Replay(changes...)
+
+	return db, nil
+}
+
+// loadSubscribers adds the subscribers to their namespaces, in the order they were created.
+func loadSubscribers(tx *bolt.Tx, db *subscribe.Subscribe) error {
+	err := tx.Bucket(bucketSubscribers).ForEach(func(key, value []byte) error {
+		namespace, _, ok := bytes.Cut(key, []byte(sep))
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrBadKey, key)
+		}
+
+		sub := new(subscribe.Subscriber)
+		if err := json.Unmarshal(value, sub); err != nil {
+			return err
+		}
+
+		ns := db.Namespace(string(namespace))
+		ns.Subscribers = append(ns.Subscribers, sub)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading subscribers: %w", err)
+	}
+
+	return nil
+}
+
+// loadEvents adds the subscriptions of each namespace and group.
+func loadEvents(tx *bolt.Tx, db *subscribe.Subscribe) error {
+	err := tx.Bucket(bucketEvents).ForEach(func(key, value []byte) error {
+		parts := strings.Split(string(key), sep)
+		if len(parts) != 3 {
+			return fmt.Errorf("%w: %q", ErrBadKey, key)
+		}
+
+		rules := new(subscribe.Rules)
+		if err := json.Unmarshal(value, rules); err != nil {
+			return err
+		}
+
+		ns := db.Namespace(parts[0])
+		events := ns.Events
+
+		if parts[1] != "" {
+			group, ok := ns.Groups[parts[1]]
+			if !ok {
+				return nil // Removing a group removes its keys; skip any stragglers.
+			}
+
+			events = group.Events
+		}
+
+		events.Map[parts[2]] = rules
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading events: %w", err)
+	}
+
+	return nil
+}
+
+// Save replaces everything in the file with db.
+func (s *Store) Save(ctx context.Context, db *subscribe.Subscribe) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketSubscribers, bucketIndex, bucketEvents, bucketChanges} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return fmt.Errorf("emptying bucket %s: %w", name, err)
+			}
+
+			if _, err := tx.CreateBucket(name); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", name, err)
+			}
+		}
+
+		if err := saveNamespace(tx, "", db); err != nil {
+			return err
+		}
+
+		for name, ns := range db.Namespaces {
+			if err := saveNamespace(tx, name, ns); err != nil {
+				return err
+			}
+		}
+
+		// Everything with its own key was removed from db, so the rest is saved as JSON.
+		return putJSON(tx.Bucket(bucketState), keyState, db)
+	})
+	if err != nil {
+		return fmt.Errorf("saving: %w", err)
+	}
+
+	return nil
+}
+
+// saveNamespace writes the subscribers and subscriptions in a namespace to their keys,
+// and removes them from ns.
+func saveNamespace(tx *bolt.Tx, name string, ns *subscribe.Subscribe) error {
+	for _, sub := range ns.Subscribers {
+		if err := insertSubscriber(tx, name, sub); err != nil {
+			return err
+		}
+	}
+
+	if err := putEvents(tx, name, "", ns.Events); err != nil {
+		return err
+	}
+
+	for group, grp := range ns.Groups {
+		if err := putEvents(tx, name, group, grp.Events); err != nil {
+			return err
+		}
+
+		grp.Events = nil
+	}
+
+	ns.Subscribers, ns.Events = nil, nil
+
+	return nil
+}
+
+// insertSubscriber adds a subscriber after the others in its namespace.
+func insertSubscriber(tx *bolt.Tx, namespace string, sub *subscribe.Subscriber) error {
+	subscribers := tx.Bucket(bucketSubscribers)
+
+	seq, err := subscribers.NextSequence()
+	if err != nil {
+		return fmt.Errorf("writing subscriber: %w", err)
+	}
+
+	key := binary.BigEndian.AppendUint64([]byte(namespace+sep), seq)
+
+	if err = putJSON(subscribers, key, sub); err != nil {
+		return err
+	}
+
+	if err = tx.Bucket(bucketIndex).Put(indexKey(namespace, sub.API, sub.ID, sub.Contact), key); err != nil {
+		return fmt.Errorf("writing subscriber: %w", err)
+	}
+
+	return nil
+}
+
+func putEvents(tx *bolt.Tx, namespace, group string, events *subscribe.Events) error {
+	if events == nil {
+		return nil
+	}
+
+	for event, rules := range events.Map {
+		if err := putJSON(tx.Bucket(bucketEvents), eventKey(namespace, group, event), rules); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexKey identifies a subscriber: by ID if it has one, like CreateSubWithID, or by contact.
+func indexKey(namespace, api string, id int64, contact string) []byte {
+	if id != 0 {
+		return []byte(namespace + sep + api + sep + "id:" + strconv.FormatInt(id, 10))
+	}
+
+	return []byte(namespace + sep + api + sep + "contact:" + contact)
+}
+
+func eventKey(namespace, group, event string) []byte {
+	return []byte(namespace + sep + group + sep + event)
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, value any) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding %q: %w", key, err)
+	}
+
+	if err = bucket.Put(key, buf); err != nil {
+		return fmt.Errorf("writing %q: %w", key, err)
+	}
+
+	return nil
+}
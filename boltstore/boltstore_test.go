@@ -0,0 +1,103 @@
+package boltstore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"golift.io/subscribe"
+	"golift.io/subscribe/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunStore(t, Extension, func(path string) (subscribe.Store, error) { return Open(path) })
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers"+Extension)
+
+	db, err := subscribe.GetDB(path)
+	require.NoError(t, err)
+
+	user := db.CreateSub("user", "pushover", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	user.Events.RuleSetD("motion", "delay", time.Minute)
+	require.NoError(t, db.Events.New("motion", nil))
+	require.NoError(t, db.Namespace("tenant").CreateSubWithID(7, "other", "slack", true, false).Subscribe("door"))
+	db.SetSeverities("info", "critical")
+	require.NoError(t, db.Close())
+
+	// Every subscriber and event is its own key, written as it changed.
+	store, err := Open(path)
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	keys := map[string][]string{}
+
+	require.NoError(t, store.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			return bucket.ForEach(func(key, _ []byte) error {
+				keys[string(name)] = append(keys[string(name)], string(key))
+				return nil
+			})
+		})
+	}))
+	assertions.Len(keys["subscribers"], 2)
+	assertions.ElementsMatch([]string{"\x00pushover\x00contact:user", "tenant\x00slack\x00id:7"}, keys["index"])
+	assertions.Equal([]string{"\x00\x00motion"}, keys["events"])
+	assertions.NotEmpty(keys["changes"], "changes without their own keys must be recorded")
+	assertions.Empty(keys["state"], "nothing was saved yet")
+}
+
+func TestWriteFold(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers"+Extension)
+
+	store, err := Open(path)
+	require.NoError(t, err)
+
+	store.foldAt = 3
+
+	db, err := subscribe.GetStoreDB(store)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateSub("user", "pushover", false, false).Subscribe("motion"))
+	db.SetSeverities("info", "critical")
+
+	for _, name := range []string{"one", "two", "three", "four", "five"} {
+		db.CreateGroup(name)
+	}
+
+	db.GroupRemove("two")
+
+	want, err := db.StateGetJSON()
+	require.NoError(t, err)
+
+	var changes int
+
+	require.NoError(t, store.db.View(func(tx *bolt.Tx) error {
+		changes = tx.Bucket(bucketChanges).Stats().KeyN
+		return nil
+	}))
+	assertions.Less(changes, 3, "recorded changes must be folded into the saved state")
+	require.NoError(t, db.Close())
+
+	loaded, err := subscribe.GetDB(path)
+	require.NoError(t, err)
+
+	defer loaded.Close()
+
+	got, err := loaded.StateGetJSON()
+	require.NoError(t, err)
+	assertions.JSONEq(want, got, "folding must keep every change")
+}
@@ -0,0 +1,20 @@
+module golift.io/subscribe/boltstore
+
+go 1.25.6
+
+toolchain go1.26.0
+
+replace golift.io/subscribe => ../
+
+require (
+	github.com/stretchr/testify v1.11.1
+	go.etcd.io/bbolt v1.5.0
+	golift.io/subscribe v0.0.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.45.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,236 @@
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+	"golift.io/subscribe"
+)
+
+// Write updates the keys for a change to a subscriber or subscription, and records other
+// changes to be replayed by Load. Each change is committed in its own transaction. Once
+// FoldChanges are recorded, they are replayed into the saved JSON and removed.
+func (s *Store) Write(change *subscribe.Change) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := write(tx, change); err != nil {
+			return err
+		}
+
+		return foldChanges(tx, s.foldAt)
+	})
+	if err != nil {
+		return fmt.Errorf("writing change: %w", err)
+	}
+
+	return nil
+}
+
+func write(tx *bolt.Tx, change *subscribe.Change) error {
+	switch change.Op {
+	case subscribe.OpSubscriber:
+		return putSubscriber(tx, change)
+	case subscribe.OpUnsubscribe:
+		return removeSubscriber(tx, change)
+	case subscribe.OpNew, subscribe.OpPause, subscribe.OpRemove,
+		subscribe.OpRename, subscribe.OpRuleSet, subscribe.OpRuleDel:
+		if change.Sub != nil {
+			return updateSubscriber(tx, change)
+		}
+
+		return updateEvents(tx, change)
+	case subscribe.OpNamespaceRemove:
+		prefix := []byte(change.Namespace + sep)
+		for _, name := range [][]byte{bucketSubscribers, bucketIndex, bucketEvents} {
+			if err := deletePrefix(tx.Bucket(name), prefix); err != nil {
+				return err
+			}
+		}
+	case subscribe.OpGroupRemove:
+		if err := deletePrefix(tx.Bucket(bucketEvents), []byte(change.Namespace+sep+change.Group+sep)); err != nil {
+			return err
+		}
+	}
+
+	// Removed namespaces and groups are recorded too, so Load removes them from the saved JSON.
+	return recordChange(tx, change)
+}
+
+// subscriberKey returns the key of the subscriber a change is for, or nil if there is none.
+func subscriberKey(tx *bolt.Tx, change *subscribe.Change) (index, key []byte) {
+	index = indexKey(change.Namespace, change.Sub.API, change.Sub.ID, change.Sub.Contact)
+
+	return index, tx.Bucket(bucketIndex).Get(index)
+}
+
+func putSubscriber(tx *bolt.Tx, change *subscribe.Change) error {
+	if change.Sub == nil {
+		return nil
+	}
+
+	if _, key := subscriberKey(tx, change); key != nil {
+		return editSubscriber(tx, key, func(sub *subscribe.Subscriber) {
+			sub.Admin, sub.Ignored = change.Admin, change.Ignored
+		})
+	}
+
+	return insertSubscriber(tx, change.Namespace, &subscribe.Subscriber{
+		ID:      change.Sub.ID,
+		API:     change.Sub.API,
+		Contact: change.Sub.Contact,
+		Admin:   change.Admin,
+		Ignored: change.Ignored,
+		Events:  &subscribe.Events{Map: make(map[string]*subscribe.Rules)},
+	})
+}
+
+func removeSubscriber(tx *bolt.Tx, change *subscribe.Change) error {
+	if change.Sub == nil {
+		return nil
+	}
+
+	index, key := subscriberKey(tx, change)
+	if key == nil {
+		return nil
+	}
+
+	if err := tx.Bucket(bucketSubscribers).Delete(key); err != nil {
+		return fmt.Errorf("removing subscriber: %w", err)
+	}
+
+	if err := tx.Bucket(bucketIndex).Delete(index); err != nil {
+		return fmt.Errorf("removing subscriber: %w", err)
+	}
+
+	return nil
+}
+
+// updateSubscriber applies a change to a subscriber's subscriptions.
+func updateSubscriber(tx *bolt.Tx, change *subscribe.Change) error {
+	_, key := subscriberKey(tx, change)
+	if key == nil {
+		return nil
+	}
+
+	return editSubscriber(tx, key, func(sub *subscribe.Subscriber) {
+		if sub.Events == nil {
+			sub.Events = new(subscribe.Events)
+		}
+
+		sub.Events.Replay(change)
+	})
+}
+
+// editSubscriber decodes a subscriber, changes it, and writes it back.
+func editSubscriber(tx *bolt.Tx, key []byte, edit func(*subscribe.Subscriber)) error {
+	subscribers := tx.Bucket(bucketSubscribers)
+	sub := new(subscribe.Subscriber)
+
+	if err := json.Unmarshal(subscribers.Get(key), sub); err != nil {
+		return fmt.Errorf("reading subscriber %q: %w", key, err)
+	}
+
+	edit(sub)
+
+	return putJSON(subscribers, key, sub)
+}
+
+// updateEvents applies a change to a subscription of the database or a group.
+// A rename touches two subscriptions; everything else touches one.
+func updateEvents(tx *bolt.Tx, change *subscribe.Change) error {
+	bucket := tx.Bucket(bucketEvents)
+	events := &subscribe.Events{Map: make(map[string]*subscribe.Rules)}
+	names := []string{change.Event}
+
+	if change.Op == subscribe.OpRename {
+		names = append(names, change.To)
+	}
+
+	for _, name := range names {
+		if value := bucket.Get(eventKey(change.Namespace, change.Group, name)); value != nil {
+			rules := new(subscribe.Rules)
+			if err := json.Unmarshal(value, rules); err != nil {
+				return fmt.Errorf("reading event %s: %w", name, err)
+			}
+
+			events.Map[name] = rules
+		}
+	}
+
+	events.Replay(change)
+
+	for _, name := range names {
+		key := eventKey(change.Namespace, change.Group, name)
+
+		if rules, ok := events.Map[name]; ok {
+			if err := putJSON(bucket, key, rules); err != nil {
+				return err
+			}
+		} else if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("removing event %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// deletePrefix deletes every key in a bucket that starts with prefix.
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	// Deleting while iterating a cursor skips keys, so collect them first.
+	keys := [][]byte{}
+	cursor := bucket.Cursor()
+
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		keys = append(keys, bytes.Clone(key))
+	}
+
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("removing %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// recordChange saves a change for Load to replay.
+func recordChange(tx *bolt.Tx, change *subscribe.Change) error {
+	changes := tx.Bucket(bucketChanges)
+
+	seq, err := changes.NextSequence()
+	if err != nil {
+		return fmt.Errorf("recording change: %w", err)
+	}
+
+	return putJSON(changes, binary.BigEndian.AppendUint64(nil, seq), change)
+}
+
+// foldChanges replays the recorded changes into the saved JSON, and removes them,
+// once there are foldAt of them.
+func foldChanges(tx *bolt.Tx, foldAt int) error {
+	// The sequence counts the changes recorded since the bucket was last emptied.
+	if tx.Bucket(bucketChanges).Sequence() < uint64(foldAt) {
+		return nil
+	}
+
+	db, err := loadState(tx)
+	if err != nil {
+		return err
+	}
+
+	if err = putJSON(tx.Bucket(bucketState), keyState, db); err != nil {
+		return err
+	}
+
+	if err = tx.DeleteBucket(bucketChanges); err != nil {
+		return fmt.Errorf("folding changes: %w", err)
+	}
+
+	if _, err = tx.CreateBucket(bucketChanges); err != nil {
+		return fmt.Errorf("folding changes: %w", err)
+	}
+
+	return nil
+}
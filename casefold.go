@@ -0,0 +1,125 @@
+package subscribe
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+/***************************
+ *    Case Fold Methods    *
+ ***************************/
+
+// EventCaseFolding turns case-insensitive event names on or off. When enabled, every
+// Events method (New, Exists, Pause, IsPaused, Remove, the Rule methods, etc.), along
+// with GetSubscribers and EventRemove, treats "Motion" and "motion" as the same event.
+// Existing events keep the case they were created with. The policy applies to the whole
+// database, including namespaces and groups, and to Merge, ImportCSV and journal replay.
+// It is not saved in the state file or Store, so enable it again every time the database
+// is opened. Use MergeCaseDuplicates to combine events that only differ by case.
+func (s *Subscribe) EventCaseFolding(enabled bool) {
+	root := s.root()
+
+	root.hookMu.Lock()
+	root.foldCase = enabled
+	root.hookMu.Unlock()
+
+	root.attachHooks()
+}
+
+// caseFolding returns true if event names are case-insensitive.
+func (s *Subscribe) caseFolding() bool {
+	root := s.root()
+
+	root.hookMu.RLock()
+	defer root.hookMu.RUnlock()
+
+	return root.foldCase
+}
+
+// MergeCaseDuplicates combines events and subscriptions whose names only differ by case,
+// and returns the number of duplicates removed. Global events keep the first name in sorted
+// order. Subscriber and group subscriptions take the global event's spelling if there is one.
+// Rules are merged like Merge with MergeNewestPause. Called on the root database this also
+// cleans every namespace. Case folding does not need to be enabled to use this.
+func (s *Subscribe) MergeCaseDuplicates() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		count  int
+		global []string
+	)
+
+	if s.Events != nil {
+		count += s.Events.mergeCaseDuplicates(nil)
+		global = s.Events.Names()
+	}
+
+	for _, sub := range s.Subscribers {
+		if sub != nil && sub.Events != nil {
+			count += sub.Events.mergeCaseDuplicates(global)
+		}
+	}
+
+	for _, group := range s.Groups {
+		count += group.Events.mergeCaseDuplicates(global)
+	}
+
+	for _, ns := range s.Namespaces {
+		count += ns.MergeCaseDuplicates()
+	}
+
+	return count
+}
+
+// mergeCaseDuplicates combines events that only differ by case, keeping the
+// spelling found in preferred, or else the first in sorted order. Returns the number removed.
+func (e *Events) mergeCaseDuplicates(preferred []string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	folded := make(map[string][]string)
+
+	for event := range e.Map {
+		lower := strings.ToLower(event)
+		folded[lower] = append(folded[lower], event)
+	}
+
+	count := 0
+
+	for _, names := range folded {
+		if len(names) < 2 {
+			continue
+		}
+
+		sort.Strings(names)
+
+		keep := names[0]
+		if idx := slices.IndexFunc(preferred, func(p string) bool { return strings.EqualFold(p, keep) }); idx >= 0 {
+			keep = preferred[idx]
+		}
+
+		var merged *Rules
+
+		for _, name := range names {
+			if merged == nil {
+				merged = cloneRules(e.Map[name])
+			} else {
+				merged, _ = mergeRules(merged, cloneRules(e.Map[name]), MergeNewestPause)
+			}
+
+			if name != keep {
+				delete(e.Map, name)
+				e.emitLocked(&Change{Op: OpRemove, Event: name})
+			}
+		}
+
+		count += len(names) - 1
+
+		e.Map[keep] = merged
+		e.emitLocked(&Change{Op: OpNew, Event: keep, Rules: merged})
+	}
+
+	return count
+}
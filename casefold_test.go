@@ -0,0 +1,166 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventCaseFolding(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	user := sub.CreateSub("user", "api", false, false)
+
+	require.NoError(t, user.Subscribe("Motion"))
+	require.NoError(t, user.Subscribe("motion"), "exact case is required until folding is enabled")
+	user.Events.Remove("motion")
+
+	sub.EventCaseFolding(true)
+	require.ErrorIs(t, user.Subscribe("MOTION"), ErrEventExists)
+	assertions.True(user.Events.Exists("motion"))
+	assertions.Equal([]string{"Motion"}, user.Events.Names(), "the original case must be kept")
+
+	require.NoError(t, user.Events.Pause("motion", time.Hour))
+	assertions.True(user.Events.IsPaused("MoTiOn"))
+	assertions.Empty(sub.GetSubscribers("MOTION"))
+	require.NoError(t, user.Events.UnPause("MOTION"))
+	assertions.Equal([]*Subscriber{user}, sub.GetSubscribers("motion"))
+
+	user.Events.RuleSetI("motion", "count", 3)
+	val, ok := user.Events.RuleGetI("MOTION", "count")
+	assertions.True(ok)
+	assertions.Equal(3, val)
+
+	require.NoError(t, user.Subscribe("Camera.#"))
+	assertions.Equal("Camera.#", user.Events.Match("camera.front"), "patterns must fold too")
+
+	// New subscribers, groups and namespaces follow the policy.
+	other := sub.CreateSub("other", "api", false, false)
+	require.NoError(t, other.Subscribe("door"))
+	assertions.True(other.Events.Exists("DOOR"))
+	assertions.True(sub.CreateGroup("oncall").Events.fold)
+	assertions.True(sub.Namespace("tenant").Events.fold)
+
+	sub.EventRemove("MOTION")
+	assertions.False(user.Events.Exists("Motion"))
+
+	sub.EventCaseFolding(false)
+	assertions.False(other.Events.Exists("DOOR"))
+}
+
+func TestMergeCaseDuplicates(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	now := time.Now()
+
+	require.NoError(t, sub.Events.New("motion", &Rules{I: map[string]int{"a": 1}}))
+	require.NoError(t, sub.Events.New("Motion", &Rules{I: map[string]int{"b": 2}}))
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Events.New("MOTION", &Rules{Pause: now, S: map[string]string{"k": "old"}}))
+	require.NoError(t, user.Events.New("motion", &Rules{Pause: now.Add(time.Hour), S: map[string]string{"k": "new"}}))
+	require.NoError(t, user.Subscribe("door"))
+
+	group := sub.CreateGroup("oncall")
+	require.NoError(t, group.Subscribe("Door"))
+	require.NoError(t, group.Subscribe("DOOR"))
+
+	ns := sub.Namespace("tenant")
+	require.NoError(t, ns.Events.New("a", nil))
+	require.NoError(t, ns.Events.New("A", nil))
+
+	assertions.Equal(4, sub.MergeCaseDuplicates())
+	assertions.Equal(0, sub.MergeCaseDuplicates(), "merging twice must not change anything")
+
+	assertions.Equal([]string{"Motion"}, sub.Events.Names(), "the first sorted name must be kept")
+	assertions.Equal(map[string]int{"a": 1, "b": 2}, sub.Events.Map["Motion"].I)
+
+	assertions.Equal([]string{"Motion", "door"}, user.Events.Names(), "the global spelling must be kept")
+	assertions.Equal("new", user.Events.Map["Motion"].S["k"], "the newest pause must win conflicts")
+	assertions.True(user.Events.Map["Motion"].Pause.Equal(now.Add(time.Hour)))
+
+	assertions.Equal([]string{"DOOR"}, group.Events.Names())
+	assertions.Equal([]string{"A"}, ns.Events.Names())
+}
+
+func TestMergeCaseDuplicatesJournal(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, user.Subscribe("Motion"))
+	assert.Equal(t, 1, sub.MergeCaseDuplicates())
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+
+	loadedUser, err := loaded.GetSubscriber("user", "api")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Motion"}, loadedUser.Events.Names())
+}
+
+func TestEventCaseFoldingMergeImport(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	sub.EventCaseFolding(true)
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("Motion"))
+	user.Events.RuleSetI("Motion", "count", 1)
+	require.NoError(t, sub.Events.New("Door", nil))
+
+	other := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	require.NoError(t, other.Events.New("DOOR", nil))
+	require.NoError(t, other.CreateSub("user", "api", false, false).Subscribe("MOTION"))
+
+	sub.Merge(other, MergeKeepLocal)
+	assertions.Equal([]string{"Door"}, sub.Events.Names(), "merging must not add a case duplicate")
+	assertions.Equal([]string{"Motion"}, user.Events.Names())
+
+	csv := "contact,api,events\nuser,api,motion\n"
+	_, err := sub.ImportCSV(strings.NewReader(csv), ImportReplace)
+	require.NoError(t, err)
+	assertions.Equal([]string{"Motion"}, user.Events.Names(), "replacing must keep a subscription that differs by case")
+
+	val, _ := user.Events.RuleGetI("motion", "count")
+	assertions.Equal(1, val, "the kept subscription must keep its rules")
+}
+
+func TestEventCaseFoldingReplay(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	// Without folding, the journal records two subscriptions that only differ by case.
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("Motion"))
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, sub.JournalDisable())
+
+	loaded := &Subscribe{stateFile: path}
+	loaded.EventCaseFolding(true)
+	require.NoError(t, loaded.StateFileLoad())
+
+	loadedUser, err := loaded.GetSubscriber("user", "api")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Motion"}, loadedUser.Events.Names(), "replay must fold like the database it loads into")
+}
@@ -126,3 +126,39 @@ func runSubscribeOps(t *testing.T, sub *Subscribe, contact string) {
 		}
 	}
 }
+
+func TestRecordsConcurrentLoad(t *testing.T) {
+	t.Parallel()
+
+	sub, err := GetDB(filepath.Join(t.TempDir(), "records.json"))
+	require.NoError(t, err)
+
+	ns := sub.Namespace("tenant")
+
+	var waitGroup sync.WaitGroup
+
+	for range 4 {
+		waitGroup.Go(func() {
+			for range 50 {
+				for _, db := range []*Subscribe{sub, ns} {
+					db.PendingDigests()
+					db.GetEscalations()
+					db.OccurrenceHistory(nil, "")
+					db.PruneOccurrences(time.Now())
+				}
+			}
+		})
+	}
+
+	waitGroup.Go(func() {
+		for range 50 {
+			if err := sub.StateFileLoad(); err != nil {
+				t.Errorf("state load failed: %v", err)
+
+				return
+			}
+		}
+	})
+
+	waitGroup.Wait()
+}
@@ -0,0 +1,13 @@
+package subscribe_test
+
+import (
+	"testing"
+
+	"golift.io/subscribe/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, ".json")
+}
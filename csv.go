@@ -0,0 +1,390 @@
+package subscribe
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*********************
+ *    CSV Methods    *
+ *********************/
+
+// ImportMode controls how ImportCSV treats subscribers already in the database.
+type ImportMode int
+
+const (
+	// ImportMerge creates or updates the subscribers in the file and adds their subscriptions.
+	// Subscribers and subscriptions missing from the file are left alone.
+	ImportMerge ImportMode = iota
+	// ImportReplace makes the database match the file. Subscribers missing from the file
+	// are removed, and subscriptions missing from a row are removed from that subscriber.
+	// Nothing is changed if any row has an error.
+	ImportReplace
+)
+
+// CSV column names. The header row must contain at least api and contact or id.
+const (
+	CSVColumnID      = "id"
+	CSVColumnAPI     = "api"
+	CSVColumnContact = "contact"
+	CSVColumnAdmin   = "admin"
+	CSVColumnIgnored = "ignored"
+	// CSVColumnEvents holds the subscribed event names separated by semicolons.
+	// Semicolons, equal signs and backslashes in event names are escaped with a backslash.
+	CSVColumnEvents = "events"
+	// CSVColumnPauses holds event=RFC3339-time pairs separated by semicolons,
+	// with event names escaped like the events column.
+	CSVColumnPauses = "pauses"
+)
+
+// csvSeparator splits multiple values in the events and pauses columns.
+const csvSeparator = ";"
+
+// csvEscaper escapes the separators in event names written to the events and pauses columns.
+var csvEscaper = strings.NewReplacer(`\`, `\\`, csvSeparator, `\`+csvSeparator, "=", `\=`)
+
+// RowError is an import problem with a single CSV row.
+type RowError struct {
+	// Row is the line number in the file; the header is row 1.
+	Row int
+	Err error
+}
+
+// Error satisfies the error interface.
+func (r *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", r.Row, r.Err)
+}
+
+// Unwrap returns the underlying error.
+func (r *RowError) Unwrap() error {
+	return r.Err
+}
+
+// ImportReport describes the outcome of ImportCSV.
+type ImportReport struct {
+	// Imported is the number of rows applied to the database.
+	Imported int
+	// Removed is the number of subscribers removed by ImportReplace.
+	Removed int
+	// Errors has one entry for every row that could not be imported.
+	Errors []*RowError
+}
+
+// csvRow is a parsed and validated CSV row.
+type csvRow struct {
+	id      int64
+	api     string
+	contact string
+	admin   bool
+	ignored bool
+	events  []string
+	pauses  map[string]time.Time
+}
+
+// ExportCSV writes every subscriber to w as CSV, with a header row.
+func (s *Subscribe) ExportCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{
+		CSVColumnID, CSVColumnAPI, CSVColumnContact, CSVColumnAdmin,
+		CSVColumnIgnored, CSVColumnEvents, CSVColumnPauses,
+	})
+	if err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	for _, sub := range s.snapshot().Subscribers {
+		if sub == nil {
+			continue
+		}
+
+		events := sub.Events.Names()
+		escaped := make([]string, 0, len(events))
+		pauses := make([]string, 0, len(events))
+
+		for _, event := range events {
+			escaped = append(escaped, csvEscaper.Replace(event))
+
+			if pause := sub.Events.Map[event].Pause; !pause.IsZero() {
+				pauses = append(pauses, csvEscaper.Replace(event)+"="+pause.Format(time.RFC3339Nano))
+			}
+		}
+
+		err = writer.Write([]string{
+			strconv.FormatInt(sub.ID, 10), sub.API, sub.Contact,
+			strconv.FormatBool(sub.Admin), strconv.FormatBool(sub.Ignored),
+			strings.Join(escaped, csvSeparator), strings.Join(pauses, csvSeparator),
+		})
+		if err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+
+	writer.Flush()
+
+	if err = writer.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	return nil
+}
+
+// ImportCSV reads subscribers from CSV written by ExportCSV, or any CSV with a header row
+// naming the same columns in any order. Rows are matched to existing subscribers the same
+// way CreateSub (by contact) and CreateSubWithID (by id, when not 0) match them.
+// Problems with individual rows are returned in the report; the error is only for
+// problems with the file as a whole.
+func (s *Subscribe) ImportCSV(r io.Reader, mode ImportMode) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing header row", ErrCSVHeader)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading csv: %w", err)
+	}
+
+	columns, err := csvColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{}
+	rows := []*csvRow{}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading csv: %w", err)
+		}
+
+		row, err := parseCSVRow(columns, record)
+		if err != nil {
+			report.Errors = append(report.Errors, &RowError{Row: line, Err: err})
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	if mode == ImportReplace && len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	kept := make(map[*Subscriber]bool, len(rows))
+
+	for _, row := range rows {
+		kept[s.importRow(row, mode)] = true
+		report.Imported++
+	}
+
+	if mode == ImportReplace {
+		s.removeSubscribers(func(sub *Subscriber) bool {
+			if kept[sub] {
+				return false
+			}
+
+			report.Removed++
+
+			return true
+		})
+	}
+
+	return report, nil
+}
+
+// importRow creates or updates the subscriber for a row and syncs its subscriptions.
+func (s *Subscribe) importRow(row *csvRow, mode ImportMode) *Subscriber {
+	var sub *Subscriber
+	if row.id != 0 {
+		sub = s.CreateSubWithID(row.id, row.contact, row.api, row.admin, row.ignored)
+	} else {
+		sub = s.CreateSub(row.contact, row.api, row.admin, row.ignored)
+	}
+
+	if mode == ImportReplace {
+		keep := make(map[string]bool, len(row.events))
+		for _, event := range row.events {
+			keep[sub.Events.key(event)] = true
+		}
+
+		for _, event := range sub.Events.Names() {
+			if !keep[event] {
+				sub.Events.Remove(event)
+			}
+		}
+	}
+
+	for _, event := range row.events {
+		// An existing subscription keeps its rules and pause time.
+		_ = sub.Subscribe(event)
+
+		if pause, ok := row.pauses[event]; ok {
+			_ = sub.Events.PauseUntil(event, pause)
+		}
+	}
+
+	return sub
+}
+
+// csvColumns maps column names to their index in the header row.
+func csvColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+
+	for idx, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = idx
+	}
+
+	if _, ok := columns[CSVColumnAPI]; !ok {
+		return nil, fmt.Errorf("%w: missing %s column", ErrCSVHeader, CSVColumnAPI)
+	}
+
+	_, hasID := columns[CSVColumnID]
+	if _, ok := columns[CSVColumnContact]; !ok && !hasID {
+		return nil, fmt.Errorf("%w: missing %s or %s column", ErrCSVHeader, CSVColumnContact, CSVColumnID)
+	}
+
+	return columns, nil
+}
+
+// parseCSVRow validates a single CSV record.
+func parseCSVRow(columns map[string]int, record []string) (*csvRow, error) {
+	field := func(name string) string {
+		if idx, ok := columns[name]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+
+		return ""
+	}
+
+	row := &csvRow{api: field(CSVColumnAPI), contact: field(CSVColumnContact)}
+
+	var err error
+
+	if row.id, err = parseCSVInt(field(CSVColumnID)); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", CSVColumnID, err)
+	}
+
+	if row.api == "" {
+		return nil, fmt.Errorf("%w: %s", ErrCSVMissingValue, CSVColumnAPI)
+	}
+
+	if row.contact == "" && row.id == 0 {
+		return nil, fmt.Errorf("%w: %s or %s", ErrCSVMissingValue, CSVColumnContact, CSVColumnID)
+	}
+
+	if row.admin, err = parseCSVBool(field(CSVColumnAdmin)); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", CSVColumnAdmin, err)
+	}
+
+	if row.ignored, err = parseCSVBool(field(CSVColumnIgnored)); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", CSVColumnIgnored, err)
+	}
+
+	for _, event := range splitCSVList(field(CSVColumnEvents)) {
+		row.events = append(row.events, unescapeCSV(event))
+	}
+
+	if row.pauses, err = parseCSVPauses(field(CSVColumnPauses), row.events); err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func parseCSVInt(val string) (int64, error) {
+	if val == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(val, 10, 64)
+}
+
+func parseCSVBool(val string) (bool, error) {
+	if val == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(val)
+}
+
+// splitCSVList splits a semicolon separated list, dropping empty entries.
+// Escaped semicolons do not split it, and the escapes are kept; see unescapeCSV.
+func splitCSVList(val string) []string {
+	list := []string{}
+
+	for more := true; more; {
+		var item string
+
+		item, val, more = cutCSV(val, csvSeparator)
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// cutCSV slices val around the first separator not escaped with a backslash, like strings.Cut.
+func cutCSV(val, separator string) (string, string, bool) {
+	for idx := 0; idx < len(val); idx++ {
+		switch {
+		case val[idx] == '\\':
+			idx++ // Skip the escaped byte.
+		case strings.HasPrefix(val[idx:], separator):
+			return val[:idx], val[idx+len(separator):], true
+		}
+	}
+
+	return val, "", false
+}
+
+// unescapeCSV removes the backslashes csvEscaper added.
+func unescapeCSV(val string) string {
+	var out strings.Builder
+
+	for idx := 0; idx < len(val); idx++ {
+		if val[idx] == '\\' && idx+1 < len(val) {
+			idx++
+		}
+
+		out.WriteByte(val[idx])
+	}
+
+	return out.String()
+}
+
+// parseCSVPauses parses event=time pairs. Every event must also be in the events column.
+func parseCSVPauses(val string, events []string) (map[string]time.Time, error) {
+	pauses := make(map[string]time.Time)
+
+	for _, item := range splitCSVList(val) {
+		event, when, ok := cutCSV(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s: %q is not event=time", CSVColumnPauses, item)
+		}
+
+		event = unescapeCSV(strings.TrimSpace(event))
+		if !slices.Contains(events, event) {
+			return nil, fmt.Errorf("invalid %s: %w: %s", CSVColumnPauses, ErrEventNotFound, event)
+		}
+
+		pause, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(when))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for %s: %w", CSVColumnPauses, event, err)
+		}
+
+		pauses[event] = pause
+	}
+
+	return pauses, nil
+}
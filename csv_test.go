@@ -0,0 +1,155 @@
+package subscribe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: new(Events)}
+	pause := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	first := sub.CreateSub("first@example.com", "smtp", true, false)
+	require.NoError(t, first.Subscribe("motion"))
+	require.NoError(t, first.Subscribe("doorbell"))
+	require.NoError(t, first.Events.PauseUntil("motion", pause))
+	sub.CreateSubWithID(7, "+15555551212", "sms", false, true)
+
+	var buf bytes.Buffer
+	require.NoError(t, sub.ExportCSV(&buf))
+
+	imported := &Subscribe{Events: new(Events)}
+	report, err := imported.ImportCSV(&buf, ImportMerge)
+	require.NoError(t, err)
+	assertions.Empty(report.Errors)
+	assertions.Equal(2, report.Imported)
+
+	got, err := imported.GetSubscriber("first@example.com", "smtp")
+	require.NoError(t, err)
+	assertions.True(got.Admin)
+	assertions.Equal([]string{"doorbell", "motion"}, got.Events.Names())
+	assertions.True(pause.Equal(got.Events.PauseTime("motion")))
+
+	got, err = imported.GetSubscriberByID(7, "sms")
+	require.NoError(t, err)
+	assertions.True(got.Ignored)
+	assertions.Equal("+15555551212", got.Contact)
+}
+
+func TestCSVEscapedEvents(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: new(Events)}
+	pause := time.Now().Add(time.Hour)
+	events := []string{`back\slash`, "key=value", "semi;colon"}
+
+	first := sub.CreateSub("first@example.com", "smtp", false, false)
+	for _, event := range events {
+		require.NoError(t, first.Subscribe(event))
+	}
+
+	require.NoError(t, first.Events.PauseUntil("key=value", pause))
+
+	var buf bytes.Buffer
+	require.NoError(t, sub.ExportCSV(&buf))
+
+	imported := &Subscribe{Events: new(Events)}
+	report, err := imported.ImportCSV(&buf, ImportMerge)
+	require.NoError(t, err)
+	assertions.Empty(report.Errors)
+
+	got, err := imported.GetSubscriber("first@example.com", "smtp")
+	require.NoError(t, err)
+	assertions.Equal(events, got.Events.Names())
+	assertions.True(pause.Equal(got.Events.PauseTime("key=value")), "pause times must keep sub-second precision")
+}
+
+func TestImportCSVMerge(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: new(Events)}
+	existing := sub.CreateSub("keep", "api", false, false)
+	require.NoError(t, existing.Subscribe("old"))
+	existing.Events.RuleSetI("old", "rule", 5)
+
+	input := "Contact,API,Admin,Events\n" +
+		"keep,api,true,new;old\n" +
+		"added,api,,new\n" +
+		",api,,\n" +
+		"bad,api,maybe,\n" +
+		"paused,api,,a,\n"
+
+	report, err := sub.ImportCSV(strings.NewReader(input), ImportMerge)
+	require.NoError(t, err)
+	assertions.Equal(3, report.Imported)
+	require.Len(t, report.Errors, 2)
+	assertions.Equal(4, report.Errors[0].Row)
+	require.ErrorIs(t, report.Errors[0], ErrCSVMissingValue)
+	assertions.Equal(5, report.Errors[1].Row)
+
+	assertions.Len(sub.Subscribers, 3)
+	assertions.True(existing.Admin)
+	assertions.Equal([]string{"new", "old"}, existing.Events.Names())
+
+	val, ok := existing.Events.RuleGetI("old", "rule")
+	assertions.True(ok, "merging must keep existing rules")
+	assertions.Equal(5, val)
+}
+
+func TestImportCSVReplace(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: new(Events)}
+	kept := sub.CreateSubWithID(1, "one", "api", false, false)
+	require.NoError(t, kept.Subscribe("dropped"))
+	require.NoError(t, kept.Subscribe("kept"))
+	sub.CreateSub("removed", "api", false, false)
+
+	// A bad row stops the whole replacement.
+	bad := "id,api,events,pauses\n1,api,kept,kept=yesterday\n"
+	report, err := sub.ImportCSV(strings.NewReader(bad), ImportReplace)
+	require.NoError(t, err)
+	assertions.Len(report.Errors, 1)
+	assertions.Zero(report.Imported)
+	assertions.Len(sub.Subscribers, 2)
+
+	good := "id,api,events,pauses\n1,api,kept;added,kept=2030-01-01T00:00:00Z\n"
+	report, err = sub.ImportCSV(strings.NewReader(good), ImportReplace)
+	require.NoError(t, err)
+	assertions.Empty(report.Errors)
+	assertions.Equal(1, report.Imported)
+	assertions.Equal(1, report.Removed)
+	require.Len(t, sub.Subscribers, 1)
+	assertions.Same(kept, sub.Subscribers[0])
+	assertions.Equal([]string{"added", "kept"}, kept.Events.Names())
+	assertions.Equal(2030, kept.Events.PauseTime("kept").Year())
+}
+
+func TestImportCSVHeader(t *testing.T) {
+	t.Parallel()
+
+	sub := &Subscribe{Events: new(Events)}
+
+	_, err := sub.ImportCSV(strings.NewReader(""), ImportMerge)
+	require.ErrorIs(t, err, ErrCSVHeader)
+
+	_, err = sub.ImportCSV(strings.NewReader("contact,events\n"), ImportMerge)
+	require.ErrorIs(t, err, ErrCSVHeader)
+
+	_, err = sub.ImportCSV(strings.NewReader("api,events\n"), ImportMerge)
+	require.ErrorIs(t, err, ErrCSVHeader)
+
+	_, err = sub.ImportCSV(strings.NewReader("api,id\n\"unterminated\n"), ImportMerge)
+	require.Error(t, err)
+}
@@ -2,10 +2,15 @@
 package subscribe
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"maps"
 	"os"
+	"slices"
 	"time"
 )
 
@@ -13,8 +18,13 @@ import (
  *   Database Methods   *
  ************************/
 
-// GetDB returns an interface to manage events.
+// GetDB returns an interface to manage events. Files with an extension registered with
+// RegisterStore are opened with that Store; call Close when done with those.
 func GetDB(stateFile string) (*Subscribe, error) {
+	if opener := storeOpener(stateFile); opener != nil {
+		return openStoreDB(opener, stateFile)
+	}
+
 	sub := &Subscribe{
 		stateFile:   stateFile,
 		EnableAPIs:  make([]string, 0),
@@ -32,6 +42,34 @@ func GetDB(stateFile string) (*Subscribe, error) {
 
 // StateFileLoad data from a json file.
 func (s *Subscribe) StateFileLoad() error {
+	return s.StateFileLoadContext(context.Background())
+}
+
+// StateFileLoadContext works like StateFileLoad, in a span of ctx's trace. See SetTracer.
+// Returns ctx's error if ctx ends before the state file and journal are read, and then
+// the database in memory is not changed.
+func (s *Subscribe) StateFileLoadContext(ctx context.Context) error {
+	if s.parent != nil {
+		return s.parent.StateFileLoadContext(ctx)
+	}
+
+	_, span := s.startSpan(ctx, "StateFileLoad")
+	span.SetAttribute(SpanAttrPath, s.stateFilePath())
+
+	start := time.Now()
+	err := s.stateFileLoad(ctx)
+	s.countLoad(time.Since(start), err)
+	s.logLoad(time.Since(start), err)
+	span.End(err)
+
+	return err
+}
+
+func (s *Subscribe) stateFileLoad(ctx context.Context) error {
+	if store := s.getStore(); store != nil {
+		return s.storeLoad(ctx, store)
+	}
+
 	s.mu.RLock()
 	stateFile := s.stateFile
 	s.mu.RUnlock()
@@ -40,32 +78,95 @@ func (s *Subscribe) StateFileLoad() error {
 		return nil
 	}
 
-	// #nosec G304 -- state file path is user-configured on purpose.
-	buf, err := os.ReadFile(stateFile)
+	loaded, err := s.decodeStateFile(ctx, stateFile)
 	if os.IsNotExist(err) {
-		return s.StateFileSave()
+		return s.StateFileSaveContext(ctx)
+	} else if err != nil {
+		return err
 	}
 
-	if err != nil {
-		return fmt.Errorf("failed reading state file: %w", err)
+	s.adoptLoaded(loaded)
+
+	return nil
+}
+
+// decodeStateFile reads a state file and replays its journal.
+// Returns an os.IsNotExist error if neither exists.
+func (s *Subscribe) decodeStateFile(ctx context.Context, stateFile string) (*Subscribe, error) {
+	buf, journals, err := s.readStateFile(ctx, stateFile)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
 	}
 
+	// The read returns with fileMu held, so the hash is only remembered if the contents decode.
+	defer s.fileMu.Unlock()
+
 	loaded := new(Subscribe)
+	read := err == nil
 
-	err = json.Unmarshal(buf, loaded)
-	if err != nil {
-		return fmt.Errorf("failed decoding state file: %w", err)
+	switch {
+	case os.IsNotExist(err) && len(journals) == 0:
+		return nil, err
+	case os.IsNotExist(err):
+		// The journal alone describes the database.
+	case err != nil:
+		return nil, fmt.Errorf("failed reading state file: %w", err)
+	default:
+		err = json.Unmarshal(buf, loaded)
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding state file: %w", err)
+		}
 	}
 
 	normalizeLoadedState(loaded)
 
+	// Journal records replay with the case folding of the database they load into.
+	if s.caseFolding() {
+		loaded.foldCase = true
+		loaded.attachHooks()
+	}
+
+	for _, journal := range journals {
+		if err = loaded.replay(journal); err != nil {
+			return nil, err
+		}
+	}
+
+	if read {
+		s.stateSum = sha256.Sum256(buf)
+	}
+
+	return loaded, nil
+}
+
+// adoptLoaded replaces the database in memory with a freshly loaded one.
+func (s *Subscribe) adoptLoaded(loaded *Subscribe) {
 	s.mu.Lock()
 	s.EnableAPIs = loaded.EnableAPIs
 	s.Events = loaded.Events
+	s.Aliases = loaded.Aliases
+	s.Definitions = loaded.Definitions
+	s.Severities = loaded.Severities
+	s.adoptRecordsLocked(loaded)
 	s.Subscribers = loaded.Subscribers
+	s.setGroupsLocked(loaded.Groups)
+	s.adoptNamespacesLocked(loaded.Namespaces)
 	s.mu.Unlock()
 
-	return nil
+	s.attachHooks()
+}
+
+// adoptRecordsLocked replaces the digests, escalations and occurrence records with loaded ones.
+// Their readers only hold digestMu or escalationMu, so those are taken too. Call with mu held.
+func (s *Subscribe) adoptRecordsLocked(loaded *Subscribe) {
+	s.digestMu.Lock()
+	s.Digests = loaded.Digests
+	s.digestMu.Unlock()
+
+	s.escalationMu.Lock()
+	s.Escalations = loaded.Escalations
+	s.Occurrences = loaded.Occurrences
+	s.escalationMu.Unlock()
 }
 
 // StateGetJSON returns the state data in json format.
@@ -78,8 +179,52 @@ func (s *Subscribe) StateGetJSON() (string, error) {
 }
 
 // StateFileSave writes out the state file.
+// When journaling is enabled this compacts the journal into the state file.
+// Returns ErrStateFileChanged if another process wrote the file since this instance last
+// read or wrote it. Call StateFileLoad to pick up those changes before saving again.
 func (s *Subscribe) StateFileSave() error {
-	const stateFileMode = 0o600
+	return s.StateFileSaveContext(context.Background())
+}
+
+// StateFileSaveContext works like StateFileSave, in a span of ctx's trace. See SetTracer.
+// Returns ctx's error if ctx ends first. Writing is not started after ctx ends,
+// but a write already in progress when it ends is finished in the background.
+func (s *Subscribe) StateFileSaveContext(ctx context.Context) error {
+	if s.parent != nil {
+		return s.parent.StateFileSaveContext(ctx)
+	}
+
+	_, span := s.startSpan(ctx, "StateFileSave")
+	span.SetAttribute(SpanAttrPath, s.stateFilePath())
+
+	err := s.stateFileSave(ctx)
+	s.countSave(err)
+	s.logSave(err)
+	span.End(err)
+
+	return err
+}
+
+// stateFilePath returns the state file path.
+func (s *Subscribe) stateFilePath() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.stateFile
+}
+
+func (s *Subscribe) stateFileSave(ctx context.Context) error {
+	if store := s.getStore(); store != nil {
+		return s.storeSave(ctx, store)
+	}
+
+	s.hookMu.RLock()
+	j := s.journal
+	s.hookMu.RUnlock()
+
+	if j != nil {
+		return s.compactJournal(ctx, j)
+	}
 
 	s.mu.RLock()
 	stateFile := s.stateFile
@@ -89,11 +234,69 @@ func (s *Subscribe) StateFileSave() error {
 		return nil
 	}
 
-	snapshot := s.snapshot()
+	return s.writeStateFile(ctx, stateFile)
+}
 
-	buf, err := json.Marshal(snapshot)
+// writeStateFile writes a snapshot of the database to a file, unless ctx ends first. Journal
+// files left next to it are removed; the database in memory already includes them, and
+// replaying them over the new state file would undo newer changes.
+func (s *Subscribe) writeStateFile(ctx context.Context, stateFile string) error {
+	buf, err := s.marshalState()
 	if err != nil {
-		return fmt.Errorf("marshaling json: %w", err)
+		return err
+	}
+
+	return s.lockStateFile(ctx, stateFile, func() error {
+		if err := s.writeStateFileLocked(stateFile, buf); err != nil {
+			return err
+		}
+
+		return removeJournal(stateFile)
+	})
+}
+
+// marshalState returns a snapshot of the database in json format.
+func (s *Subscribe) marshalState() ([]byte, error) {
+	buf, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling json: %w", err)
+	}
+
+	return buf, nil
+}
+
+// lockStateFile runs fn with fileMu and an exclusive lock on the state file held, unless ctx ends first.
+func (s *Subscribe) lockStateFile(ctx context.Context, stateFile string, fn func() error) error {
+	return runContext(ctx, func() error {
+		s.fileMu.Lock()
+		defer s.fileMu.Unlock()
+
+		unlock, err := lockFile(stateFile, true)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		// Waiting for the locks may have taken a while.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return fn()
+	}, nil)
+}
+
+// writeStateFileLocked writes buf to the state file, if nobody else changed it.
+// Call with fileMu and the lock file held.
+func (s *Subscribe) writeStateFileLocked(stateFile string, buf []byte) error {
+	const stateFileMode = 0o600
+
+	// #nosec G304 -- state file path is user-configured on purpose.
+	current, err := os.ReadFile(stateFile)
+	if err == nil && sha256.Sum256(current) != s.stateSum {
+		return ErrStateFileChanged
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading file: %w", err)
 	}
 
 	err = os.WriteFile(stateFile, buf, stateFileMode)
@@ -101,23 +304,133 @@ func (s *Subscribe) StateFileSave() error {
 		return fmt.Errorf("writing file: %w", err)
 	}
 
+	s.stateSum = sha256.Sum256(buf)
+
 	return nil
 }
 
+// readStateFile reads the state file and its journal, unless ctx ends first. The journal is
+// returned with an os.IsNotExist error for the state file. Unless ctx's error is returned,
+// this returns with fileMu held; the caller must unlock it.
+func (s *Subscribe) readStateFile(ctx context.Context, stateFile string) ([]byte, [][]byte, error) {
+	var (
+		buf      []byte
+		journals [][]byte
+	)
+
+	err := runContext(ctx, func() error {
+		s.fileMu.Lock()
+
+		unlock, err := lockFile(stateFile, false)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		// #nosec G304 -- state file path is user-configured on purpose.
+		buf, err = os.ReadFile(stateFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		var jErr error
+		if journals, jErr = readJournal(stateFile); jErr != nil {
+			return jErr
+		}
+
+		return err
+	}, s.fileMu.Unlock)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, nil, err // The read was abandoned, or never started.
+	}
+
+	return buf, journals, err
+}
+
+// runContext runs fn, which blocks on file I/O, in a goroutine and waits for it to return or for ctx
+// to end. File I/O cannot be interrupted, so when ctx ends first fn keeps running in the background,
+// and abandon, if not nil, is called after it returns. Returns fn's error or ctx's error;
+// fn must not return context errors.
+func runContext(ctx context.Context, fn func() error, abandon func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if abandon != nil {
+			go func() {
+				<-done
+				abandon()
+			}()
+		}
+
+		return ctx.Err()
+	}
+}
+
 // StateFileRelocate writes the state file to a new location.
+// If journaling is enabled, it continues with a journal next to the new state file.
+// Returns ErrStoreInUse if the database was opened with GetStoreDB.
 func (s *Subscribe) StateFileRelocate(newPath string) error {
+	return s.StateFileRelocateContext(context.Background(), newPath)
+}
+
+// StateFileRelocateContext works like StateFileRelocate, and stops waiting for file I/O when ctx ends.
+// If ctx ends before the new state file is loaded, the old state file stays in use and ctx's error is
+// returned. Journaling, if enabled, is enabled again either way.
+func (s *Subscribe) StateFileRelocateContext(ctx context.Context, newPath string) error {
+	if s.parent != nil {
+		return s.parent.StateFileRelocateContext(ctx, newPath)
+	}
+
+	if s.getStore() != nil {
+		return ErrStoreInUse
+	}
+
+	s.hookMu.RLock()
+	j := s.journal
+	s.hookMu.RUnlock()
+
+	if j != nil {
+		if err := s.JournalDisable(); err != nil {
+			return err
+		}
+	}
+
 	s.mu.Lock()
 	oldPath := s.stateFile
 	s.stateFile = newPath
 	s.mu.Unlock()
 
-	err := s.StateFileLoad()
+	err := s.StateFileLoadContext(ctx)
 	if err != nil {
 		s.mu.Lock()
 		s.stateFile = oldPath
 		s.mu.Unlock()
 	}
 
+	if j != nil && newPath != "" {
+		if jErr := s.journalEnable(context.WithoutCancel(ctx), j.compactAt); err == nil {
+			err = jErr
+		}
+	}
+
+	from, to := slog.String("from", oldPath), slog.String("to", newPath)
+	if err != nil {
+		s.queueLog(slog.LevelError, "Relocating state file failed", from, to, slog.Any("error", err))
+	} else {
+		s.queueLog(slog.LevelInfo, "Relocated state file", from, to)
+	}
+
+	s.flushLogs()
+
 	return err
 }
 
@@ -149,6 +462,55 @@ func normalizeLoadedState(loaded *Subscribe) {
 
 		normalizeEvents(sub.Events)
 	}
+
+	for name, group := range loaded.Groups {
+		if group == nil {
+			delete(loaded.Groups, name)
+			continue
+		}
+
+		group.Name = name
+
+		if group.Members == nil {
+			group.Members = make([]*SubscriberRef, 0)
+		}
+
+		group.Members = slices.DeleteFunc(group.Members, func(ref *SubscriberRef) bool { return ref == nil })
+
+		if group.Events == nil {
+			group.Events = &Events{Map: make(map[string]*Rules)}
+		} else {
+			normalizeEvents(group.Events)
+		}
+	}
+
+	loaded.Digests = slices.DeleteFunc(loaded.Digests, func(digest *Digest) bool { return digest == nil })
+	loaded.Escalations = slices.DeleteFunc(loaded.Escalations, func(esc *Escalation) bool {
+		return esc == nil || esc.Occurrence == nil
+	})
+	loaded.Occurrences = slices.DeleteFunc(loaded.Occurrences, func(rec *OccurrenceRecord) bool {
+		return rec == nil || rec.Occurrence == nil
+	})
+
+	for name, def := range loaded.Definitions {
+		if def == nil {
+			delete(loaded.Definitions, name)
+			continue
+		}
+
+		def.Name = name
+	}
+
+	for name, ns := range loaded.Namespaces {
+		if ns == nil {
+			ns = new(Subscribe)
+			loaded.Namespaces[name] = ns
+		}
+
+		ns.Namespaces = nil
+		ns.parent, ns.namespace = loaded, name
+		normalizeLoadedState(ns)
+	}
 }
 
 func normalizeEvents(events *Events) {
@@ -193,6 +555,12 @@ func (s *Subscribe) snapshot() *Subscribe {
 	out := &Subscribe{
 		EnableAPIs:  append(make([]string, 0, len(s.EnableAPIs)), s.EnableAPIs...),
 		Events:      snapshotEvents(s.Events),
+		Aliases:     maps.Clone(s.Aliases),
+		Definitions: snapshotDefinitions(s.Definitions),
+		Severities:  slices.Clone(s.Severities),
+		Digests:     s.snapshotDigests(),
+		Escalations: s.snapshotEscalations(),
+		Occurrences: s.snapshotOccurrences(),
 		Subscribers: make([]*Subscriber, 0, len(s.Subscribers)),
 	}
 
@@ -200,6 +568,20 @@ func (s *Subscribe) snapshot() *Subscribe {
 		out.Subscribers = append(out.Subscribers, snapshotSubscriber(sub))
 	}
 
+	if len(s.Groups) > 0 {
+		out.Groups = make(map[string]*Group, len(s.Groups))
+		for name, group := range s.Groups {
+			out.Groups[name] = snapshotGroup(group)
+		}
+	}
+
+	if len(s.Namespaces) > 0 {
+		out.Namespaces = make(map[string]*Subscribe, len(s.Namespaces))
+		for name, ns := range s.Namespaces {
+			out.Namespaces[name] = ns.snapshot()
+		}
+	}
+
 	return out
 }
 
@@ -1,6 +1,7 @@
 package subscribe
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -101,6 +102,33 @@ func TestStateFileRelocate(t *testing.T) {
 	assertions.Equal(testFile4, sub.stateFile, "the path was not changed back to the previous value")
 }
 
+func TestStateFileContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	sub.CreateSub("user", "pushover", false, false)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	require.ErrorIs(t, sub.StateFileSaveContext(ctx), context.Canceled)
+	require.ErrorIs(t, sub.StateFileLoadContext(ctx), context.Canceled)
+	assertions.Len(sub.Subscribers, 1, "a canceled load must not change the database")
+
+	newPath := filepath.Join(t.TempDir(), "moved.json")
+	require.ErrorIs(t, sub.StateFileRelocateContext(ctx, newPath), context.Canceled)
+	assertions.Equal(path, sub.stateFile, "a canceled relocation must keep the old state file")
+	assertions.NoFileExists(newPath)
+
+	require.NoError(t, sub.StateFileSaveContext(t.Context()), "the file locks must be released")
+	require.NoError(t, sub.StateFileLoadContext(t.Context()))
+	assertions.Len(sub.Subscribers, 1)
+}
+
 func TestStateGetJSONMarshalError(t *testing.T) {
 	t.Parallel()
 
@@ -130,3 +158,43 @@ func TestStateFileSaveMarshalError(t *testing.T) {
 	require.Error(t, err)
 	assert.ErrorContains(t, err, "marshaling json")
 }
+
+func TestStateFileSaveConflict(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "state-conflict.json")
+
+	first, err := GetDB(stateFile)
+	require.NoError(t, err)
+	second, err := GetDB(stateFile)
+	require.NoError(t, err)
+
+	first.CreateSub("first", "api", false, false)
+	require.NoError(t, first.StateFileSave())
+
+	second.CreateSub("second", "api", false, false)
+	require.ErrorIs(t, second.StateFileSave(), ErrStateFileChanged,
+		"saving over a file changed by another instance must fail")
+
+	require.NoError(t, second.StateFileLoad())
+	second.CreateSub("second", "api", false, false)
+	require.NoError(t, second.StateFileSave(), "saving after a reload must work")
+	assert.Len(t, second.Subscribers, 2)
+}
+
+func TestStateFileRelocateUndecodable(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "good.json")
+	badFile := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(badFile, []byte("this aint good json}}"), 0o600))
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+	require.Error(t, sub.StateFileRelocate(badFile))
+	assert.Equal(t, stateFile, sub.stateFile)
+
+	sub.CreateSub("contact", "api", false, false)
+	require.NoError(t, sub.StateFileSave(), "a failed relocate must not keep the other file's hash")
+}
@@ -0,0 +1,180 @@
+package subscribe
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+/****************************
+ *    Definition Methods    *
+ ****************************/
+
+// DefineEvent adds or replaces an event definition, and adds the event to the global
+// Events list if it is missing. The definition is copied; change it by calling this again.
+// Returns ErrInvalidDefinition if def is nil or has no Name.
+func (s *Subscribe) DefineEvent(def *EventDefinition) error {
+	if def == nil || def.Name == "" {
+		return ErrInvalidDefinition
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defineLocked(cloneDefinition(def))
+
+	if s.Events != nil && !s.Events.Exists(def.Name) {
+		_ = s.Events.New(def.Name, nil)
+	}
+
+	return nil
+}
+
+// GetDefinition returns a copy of an event's definition.
+func (s *Subscribe) GetDefinition(event string) (*EventDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if def := s.definitionLocked(event); def != nil {
+		return cloneDefinition(def), nil
+	}
+
+	return nil, ErrEventNotFound
+}
+
+// EventCatalog returns a copy of every event definition, sorted by category and name.
+func (s *Subscribe) EventCatalog() []*EventDefinition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	catalog := make([]*EventDefinition, 0, len(s.Definitions))
+
+	for _, def := range s.Definitions {
+		catalog = append(catalog, cloneDefinition(def))
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].Category != catalog[j].Category {
+			return catalog[i].Category < catalog[j].Category
+		}
+
+		return catalog[i].Name < catalog[j].Name
+	})
+
+	return catalog
+}
+
+// UndefineEvent deletes an event definition. The event and its subscriptions are not affected.
+func (s *Subscribe) UndefineEvent(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.undefineLocked(event)
+}
+
+// defaultRules returns a copy of an event definition's default rules, or nil.
+func (s *Subscribe) defaultRules(event string) *Rules {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if def := s.definitionLocked(event); def != nil && def.Defaults != nil {
+		return cloneRules(def.Defaults)
+	}
+
+	return nil
+}
+
+// definitionLocked finds an event's definition, honoring case folding. Call with mu held.
+func (s *Subscribe) definitionLocked(event string) *EventDefinition {
+	if def, ok := s.Definitions[event]; ok || !s.caseFolding() {
+		return def
+	}
+
+	for name, def := range s.Definitions {
+		if strings.EqualFold(name, event) {
+			return def
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscribe) defineLocked(def *EventDefinition) {
+	if s.Definitions == nil {
+		s.Definitions = make(map[string]*EventDefinition)
+	}
+
+	s.Definitions[def.Name] = def
+	s.emit(&Change{Op: OpDefine, Event: def.Name, Def: def})
+}
+
+func (s *Subscribe) undefineLocked(event string) {
+	if def := s.definitionLocked(event); def != nil {
+		delete(s.Definitions, def.Name)
+		s.emit(&Change{Op: OpUndefine, Event: def.Name})
+	}
+}
+
+// applyDefinition replays a definition change.
+func (s *Subscribe) applyDefinition(record *Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.Op == OpUndefine {
+		s.undefineLocked(record.Event)
+	} else if record.Def != nil {
+		s.defineLocked(cloneDefinition(record.Def))
+	}
+}
+
+// subscribe adds a subscription that starts with the event's default rules.
+func (e *Events) subscribe(event string) error {
+	e.mu.RLock()
+	defaults := e.defaults
+	e.mu.RUnlock()
+
+	var rules *Rules
+	if defaults != nil {
+		rules = defaults(event)
+	}
+
+	if rules == nil {
+		rules = &Rules{}
+	}
+
+	rules.Pause = time.Now()
+
+	return e.New(event, rules)
+}
+
+func cloneDefinition(def *EventDefinition) *EventDefinition {
+	out := *def
+	if def.Defaults != nil {
+		out.Defaults = cloneRules(def.Defaults)
+	}
+
+	if def.Escalation != nil {
+		out.Escalation = &EscalationPolicy{Steps: make([]*EscalationStep, 0, len(def.Escalation.Steps))}
+		for _, step := range def.Escalation.Steps {
+			if step != nil {
+				cloned := *step
+				out.Escalation.Steps = append(out.Escalation.Steps, &cloned)
+			}
+		}
+	}
+
+	return &out
+}
+
+func snapshotDefinitions(defs map[string]*EventDefinition) map[string]*EventDefinition {
+	if len(defs) == 0 {
+		return nil
+	}
+
+	out := make(map[string]*EventDefinition, len(defs))
+	for name, def := range defs {
+		out[name] = cloneDefinition(def)
+	}
+
+	return out
+}
@@ -0,0 +1,107 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventDefinitions(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	def := &EventDefinition{
+		Name:        "motion",
+		Description: "Motion detected by a camera",
+		Category:    "camera",
+		Owner:       "security",
+		Severity:    "warning",
+		Defaults:    &Rules{D: map[string]time.Duration{"cooldown": time.Minute}, S: map[string]string{"sound": "chime"}},
+	}
+
+	require.NoError(t, sub.DefineEvent(def))
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "door", Category: "access"}))
+	require.ErrorIs(t, sub.DefineEvent(nil), ErrInvalidDefinition)
+	require.ErrorIs(t, sub.DefineEvent(&EventDefinition{Description: "no name"}), ErrInvalidDefinition)
+	def.Description = "changed"
+
+	assertions.True(sub.Events.Exists("motion"), "defining an event must add it")
+
+	got, err := sub.GetDefinition("motion")
+	require.NoError(t, err)
+	assertions.Equal("Motion detected by a camera", got.Description, "the definition must be a copy")
+
+	_, err = sub.GetDefinition("missing")
+	require.ErrorIs(t, err, ErrEventNotFound)
+
+	catalog := sub.EventCatalog()
+	require.Len(t, catalog, 2)
+	assertions.Equal("door", catalog[0].Name, "the catalog must be sorted by category")
+	assertions.Equal("motion", catalog[1].Name)
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, user.Subscribe("door"))
+
+	cooldown, ok := user.Events.RuleGetD("motion", "cooldown")
+	assertions.True(ok, "new subscriptions must start with the default rules")
+	assertions.Equal(time.Minute, cooldown)
+	assertions.False(user.Events.IsPaused("motion"))
+
+	user.Events.RuleSetS("motion", "sound", "bell")
+	got, _ = sub.GetDefinition("motion")
+	assertions.Equal("chime", got.Defaults.S["sound"], "subscriptions must not share the default rules")
+
+	group := sub.CreateGroup("oncall")
+	require.NoError(t, group.Subscribe("motion"))
+	assertions.Equal("chime", group.Events.Map["motion"].S["sound"])
+
+	require.NoError(t, sub.EventRename("motion", "movement"))
+	got, err = sub.GetDefinition("movement")
+	require.NoError(t, err)
+	assertions.Equal("movement", got.Name)
+
+	sub.EventRemove("movement")
+	_, err = sub.GetDefinition("movement")
+	require.ErrorIs(t, err, ErrEventNotFound)
+
+	sub.UndefineEvent("door")
+	assertions.Empty(sub.EventCatalog())
+	assertions.True(sub.Events.Exists("door"), "undefining an event must not remove it")
+}
+
+func TestEventDefinitionsPersist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	defaults := &Rules{I: map[string]int{"a": 1}}
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "motion", Category: "camera", Defaults: defaults}))
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "door"}))
+	sub.UndefineEvent("door")
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+
+	catalog := loaded.EventCatalog()
+	require.Len(t, catalog, 1)
+	assert.Equal(t, "camera", catalog[0].Category)
+	assert.Equal(t, 1, catalog[0].Defaults.I["a"])
+
+	require.NoError(t, loaded.StateFileSave())
+
+	saved, err := GetDB(path)
+	require.NoError(t, err)
+
+	def, err := saved.GetDefinition("motion")
+	require.NoError(t, err)
+	assert.Equal(t, "motion", def.Name)
+}
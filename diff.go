@@ -0,0 +1,291 @@
+package subscribe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**********************
+ *    Diff Methods    *
+ **********************/
+
+// DiffReport describes the differences between two databases. Encode it with
+// encoding/json for a machine-readable report, or call String() for a text report.
+type DiffReport struct {
+	EventsAdded        []string          `json:"eventsAdded"`
+	EventsRemoved      []string          `json:"eventsRemoved"`
+	EventsChanged      []*EventDiff      `json:"eventsChanged"`
+	SubscribersAdded   []*SubscriberRef  `json:"subscribersAdded"`
+	SubscribersRemoved []*SubscriberRef  `json:"subscribersRemoved"`
+	SubscribersChanged []*SubscriberDiff `json:"subscribersChanged"`
+}
+
+// SubscriberDiff describes the changes to a subscriber found in both databases.
+type SubscriberDiff struct {
+	SubscriberRef
+
+	Admin         *BoolChange  `json:"admin,omitempty"`
+	Ignored       *BoolChange  `json:"ignored,omitempty"`
+	EventsAdded   []string     `json:"eventsAdded,omitempty"`
+	EventsRemoved []string     `json:"eventsRemoved,omitempty"`
+	EventsChanged []*EventDiff `json:"eventsChanged,omitempty"`
+}
+
+// BoolChange is a flag that changed.
+type BoolChange struct {
+	From bool `json:"from"`
+	To   bool `json:"to"`
+}
+
+// EventDiff describes the changes to an event, or an event subscription, found in both databases.
+type EventDiff struct {
+	Event string       `json:"event"`
+	Pause *PauseChange `json:"pause,omitempty"`
+	Rules []*RuleDiff  `json:"rules,omitempty"`
+}
+
+// PauseChange is a pause time that changed.
+type PauseChange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// RuleDiff is a rule that was added, removed or changed.
+// Kind is D, I, S or T, matching the Rules maps. From is nil for an added rule
+// and To is nil for a removed rule.
+type RuleDiff struct {
+	Kind string `json:"kind"`
+	Rule string `json:"rule"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// Diff compares two databases and reports what it takes to turn a into b.
+// Subscribers are matched the way CreateSubWithID (by ID, when not 0) and
+// CreateSub (by contact) match them.
+func Diff(a, b *Subscribe) *DiffReport {
+	from, into := a.snapshot(), b.snapshot()
+	report := &DiffReport{
+		SubscribersAdded:   []*SubscriberRef{},
+		SubscribersRemoved: []*SubscriberRef{},
+		SubscribersChanged: []*SubscriberDiff{},
+	}
+
+	report.EventsAdded, report.EventsRemoved, report.EventsChanged = diffEvents(from.Events, into.Events)
+
+	for _, sub := range from.Subscribers {
+		if sub != nil && into.findSubscriberLocked(sub.ID, sub.Contact, sub.API) == nil {
+			report.SubscribersRemoved = append(report.SubscribersRemoved, sub.ref())
+		}
+	}
+
+	for _, sub := range into.Subscribers {
+		if sub == nil {
+			continue
+		}
+
+		old := from.findSubscriberLocked(sub.ID, sub.Contact, sub.API)
+		if old == nil {
+			report.SubscribersAdded = append(report.SubscribersAdded, sub.ref())
+		} else if changed := diffSubscriber(old, sub); changed != nil {
+			report.SubscribersChanged = append(report.SubscribersChanged, changed)
+		}
+	}
+
+	return report
+}
+
+// Empty returns true if the report has no differences.
+func (d *DiffReport) Empty() bool {
+	return len(d.EventsAdded) == 0 && len(d.EventsRemoved) == 0 && len(d.EventsChanged) == 0 &&
+		len(d.SubscribersAdded) == 0 && len(d.SubscribersRemoved) == 0 && len(d.SubscribersChanged) == 0
+}
+
+// String renders the report as text, one change per line.
+// Lines start with + for additions, - for removals and ~ for changes.
+func (d *DiffReport) String() string {
+	var buf strings.Builder
+
+	for _, event := range d.EventsAdded {
+		fmt.Fprintf(&buf, "+ event %q\n", event)
+	}
+
+	for _, event := range d.EventsRemoved {
+		fmt.Fprintf(&buf, "- event %q\n", event)
+	}
+
+	for _, event := range d.EventsChanged {
+		event.write(&buf, "")
+	}
+
+	for _, sub := range d.SubscribersAdded {
+		fmt.Fprintf(&buf, "+ subscriber %s\n", sub)
+	}
+
+	for _, sub := range d.SubscribersRemoved {
+		fmt.Fprintf(&buf, "- subscriber %s\n", sub)
+	}
+
+	for _, sub := range d.SubscribersChanged {
+		sub.write(&buf)
+	}
+
+	return buf.String()
+}
+
+// String renders a subscriber as api:contact, with the ID if it has one.
+func (r *SubscriberRef) String() string {
+	if r.ID != 0 {
+		return fmt.Sprintf("%s:%s (id %d)", r.API, r.Contact, r.ID)
+	}
+
+	return r.API + ":" + r.Contact
+}
+
+func (d *SubscriberDiff) write(buf *strings.Builder) {
+	fmt.Fprintf(buf, "~ subscriber %s\n", &d.SubscriberRef)
+
+	if d.Admin != nil {
+		fmt.Fprintf(buf, "  ~ admin %v -> %v\n", d.Admin.From, d.Admin.To)
+	}
+
+	if d.Ignored != nil {
+		fmt.Fprintf(buf, "  ~ ignored %v -> %v\n", d.Ignored.From, d.Ignored.To)
+	}
+
+	for _, event := range d.EventsAdded {
+		fmt.Fprintf(buf, "  + subscription %q\n", event)
+	}
+
+	for _, event := range d.EventsRemoved {
+		fmt.Fprintf(buf, "  - subscription %q\n", event)
+	}
+
+	for _, event := range d.EventsChanged {
+		event.write(buf, "  ")
+	}
+}
+
+func (d *EventDiff) write(buf *strings.Builder, indent string) {
+	fmt.Fprintf(buf, "%s~ event %q\n", indent, d.Event)
+
+	if d.Pause != nil {
+		fmt.Fprintf(buf, "%s  ~ pause %s -> %s\n", indent,
+			d.Pause.From.Format(time.RFC3339), d.Pause.To.Format(time.RFC3339))
+	}
+
+	for _, rule := range d.Rules {
+		switch {
+		case rule.From == nil:
+			fmt.Fprintf(buf, "%s  + rule %s %q = %v\n", indent, rule.Kind, rule.Rule, rule.To)
+		case rule.To == nil:
+			fmt.Fprintf(buf, "%s  - rule %s %q = %v\n", indent, rule.Kind, rule.Rule, rule.From)
+		default:
+			fmt.Fprintf(buf, "%s  ~ rule %s %q %v -> %v\n", indent, rule.Kind, rule.Rule, rule.From, rule.To)
+		}
+	}
+}
+
+// diffSubscriber compares two matched subscribers. Returns nil if they are the same.
+func diffSubscriber(from, into *Subscriber) *SubscriberDiff {
+	diff := &SubscriberDiff{SubscriberRef: *into.ref()}
+
+	if from.Admin != into.Admin {
+		diff.Admin = &BoolChange{From: from.Admin, To: into.Admin}
+	}
+
+	if from.Ignored != into.Ignored {
+		diff.Ignored = &BoolChange{From: from.Ignored, To: into.Ignored}
+	}
+
+	diff.EventsAdded, diff.EventsRemoved, diff.EventsChanged = diffEvents(from.Events, into.Events)
+
+	if diff.Admin == nil && diff.Ignored == nil && len(diff.EventsAdded) == 0 &&
+		len(diff.EventsRemoved) == 0 && len(diff.EventsChanged) == 0 {
+		return nil
+	}
+
+	return diff
+}
+
+// diffEvents compares two (snapshot) Events maps. Returns added, removed and changed events.
+func diffEvents(from, into *Events) ([]string, []string, []*EventDiff) {
+	added, removed, changed := []string{}, []string{}, []*EventDiff{}
+
+	for _, event := range from.Names() {
+		if _, ok := into.Map[event]; !ok {
+			removed = append(removed, event)
+		}
+	}
+
+	for _, event := range into.Names() {
+		old, ok := from.Map[event]
+		if !ok {
+			added = append(added, event)
+			continue
+		}
+
+		if diff := diffRules(event, old, into.Map[event]); diff != nil {
+			changed = append(changed, diff)
+		}
+	}
+
+	return added, removed, changed
+}
+
+// diffRules compares two rule sets for the same event. Returns nil if they are the same.
+func diffRules(event string, from, into *Rules) *EventDiff {
+	diff := &EventDiff{Event: event}
+
+	if !from.Pause.Equal(into.Pause) {
+		diff.Pause = &PauseChange{From: from.Pause, To: into.Pause}
+	}
+
+	diff.Rules = append(diff.Rules, diffRuleMap("D", from.D, into.D, equal[time.Duration])...)
+	diff.Rules = append(diff.Rules, diffRuleMap("I", from.I, into.I, equal[int])...)
+	diff.Rules = append(diff.Rules, diffRuleMap("S", from.S, into.S, equal[string])...)
+	diff.Rules = append(diff.Rules, diffRuleMap("T", from.T, into.T, time.Time.Equal)...)
+
+	if diff.Pause == nil && len(diff.Rules) == 0 {
+		return nil
+	}
+
+	return diff
+}
+
+// diffRuleMap compares one kind of rule, sorted by rule name.
+func diffRuleMap[V any](kind string, from, into map[string]V, equal func(V, V) bool) []*RuleDiff {
+	names := make([]string, 0, len(from)+len(into))
+
+	for name := range from {
+		names = append(names, name)
+	}
+
+	for name := range into {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	diffs := []*RuleDiff{}
+
+	for _, name := range names {
+		old, inFrom := from[name]
+		val, inInto := into[name]
+
+		switch {
+		case !inFrom:
+			diffs = append(diffs, &RuleDiff{Kind: kind, Rule: name, To: val})
+		case !inInto:
+			diffs = append(diffs, &RuleDiff{Kind: kind, Rule: name, From: old})
+		case !equal(old, val):
+			diffs = append(diffs, &RuleDiff{Kind: kind, Rule: name, From: old, To: val})
+		}
+	}
+
+	return diffs
+}
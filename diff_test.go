@@ -0,0 +1,84 @@
+package subscribe
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	before := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	require.NoError(t, before.Events.New("kept", nil))
+	require.NoError(t, before.Events.New("dropped", nil))
+
+	sub := before.CreateSub("changed", "api", false, false)
+	require.NoError(t, sub.Subscribe("evt"))
+	require.NoError(t, sub.Subscribe("gone"))
+	sub.Events.RuleSetI("evt", "count", 1)
+	sub.Events.RuleSetS("evt", "removed", "x")
+	before.CreateSub("removed", "api", false, false)
+	before.CreateSub("same", "api", false, false)
+
+	// Build the second database from the first, then change it.
+	after := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	after.Merge(before, MergeKeepRemote)
+	assertions.True(Diff(before, after).Empty(), "a copy must have no differences")
+
+	after.EventRemove("dropped")
+	require.NoError(t, after.Events.New("added", nil))
+	after.Events.RuleSetD("kept", "wait", time.Minute)
+	after.CreateSubWithID(3, "new", "api", false, false)
+	after.removeSubscribers(func(s *Subscriber) bool { return s.Contact == "removed" })
+
+	changed := after.CreateSub("changed", "api", true, false)
+	require.NoError(t, changed.Subscribe("fresh"))
+	changed.Events.Remove("gone")
+	changed.Events.RuleSetI("evt", "count", 2)
+	changed.Events.RuleDelS("evt", "removed")
+	changed.Events.RuleSetT("evt", "when", time.Unix(0, 0))
+	require.NoError(t, changed.Events.PauseUntil("evt", time.Unix(100, 0)))
+
+	report := Diff(before, after)
+	assertions.False(report.Empty())
+	assertions.Equal([]string{"added"}, report.EventsAdded)
+	assertions.Equal([]string{"dropped"}, report.EventsRemoved)
+	require.Len(t, report.EventsChanged, 1)
+	assertions.Equal(&RuleDiff{Kind: "D", Rule: "wait", To: time.Minute}, report.EventsChanged[0].Rules[0])
+	assertions.Equal([]*SubscriberRef{{ID: 3, Contact: "new", API: "api"}}, report.SubscribersAdded)
+	assertions.Equal([]*SubscriberRef{{Contact: "removed", API: "api"}}, report.SubscribersRemoved)
+
+	require.Len(t, report.SubscribersChanged, 1)
+	diff := report.SubscribersChanged[0]
+	assertions.Equal(&BoolChange{From: false, To: true}, diff.Admin)
+	assertions.Nil(diff.Ignored)
+	assertions.Equal([]string{"fresh"}, diff.EventsAdded)
+	assertions.Equal([]string{"gone"}, diff.EventsRemoved)
+	require.Len(t, diff.EventsChanged, 1)
+	assertions.NotNil(diff.EventsChanged[0].Pause)
+	assertions.Equal([]*RuleDiff{
+		{Kind: "I", Rule: "count", From: 1, To: 2},
+		{Kind: "S", Rule: "removed", From: "x"},
+		{Kind: "T", Rule: "when", To: time.Unix(0, 0)},
+	}, diff.EventsChanged[0].Rules)
+
+	text := report.String()
+	assertions.Contains(text, "+ event \"added\"\n")
+	assertions.Contains(text, "- event \"dropped\"\n")
+	assertions.Contains(text, "+ subscriber api:new (id 3)\n")
+	assertions.Contains(text, "- subscriber api:removed\n")
+	assertions.Contains(text, "~ subscriber api:changed\n  ~ admin false -> true\n")
+	assertions.Contains(text, "    ~ rule I \"count\" 1 -> 2\n")
+	assertions.Contains(text, "    - rule S \"removed\" = x\n")
+	assertions.Contains(text, "  ~ event \"evt\"\n    ~ pause ")
+
+	buf, err := json.Marshal(report)
+	require.NoError(t, err)
+	assertions.Contains(string(buf), `"admin":{"from":false,"to":true}`)
+	assertions.Contains(string(buf), `{"kind":"S","rule":"removed","from":"x"}`)
+}
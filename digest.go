@@ -0,0 +1,202 @@
+package subscribe
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+)
+
+/************************
+ *    Digest Methods    *
+ ************************/
+
+// RuleDigest is the duration rule holding a subscription's digest window. Set it with SetDigest.
+const RuleDigest = "digest"
+
+// Digest holds the occurrences collected for one digest subscription during one window.
+type Digest struct {
+	// Subscriber identifies who the digest is for.
+	Subscriber SubscriberRef `json:"subscriber"`
+	// Event is the subscription the occurrences matched; this may be a pattern.
+	Event string `json:"event"`
+	// Start is the time of the first occurrence. The window ends at Start plus Every.
+	Start time.Time `json:"start"`
+	// Every is the length of the window.
+	Every time.Duration `json:"every"`
+	// Occurrences are the collected occurrences, oldest first.
+	Occurrences []*Occurrence `json:"occurrences"`
+}
+
+// DigestBatch is every digest flushed for a single subscriber.
+type DigestBatch struct {
+	Subscriber *Subscriber
+	Digests    []*Digest
+}
+
+// SetDigest turns a subscription into a digest subscription, or with every <= 0, back
+// into a normal one. Digest subscribers are not returned by GetSubscribers and the other
+// lookups. Occurrences passed to Dispatch are collected for them instead, and returned by
+// FlushDigests once every has elapsed since the first one.
+func (e *Events) SetDigest(event string, every time.Duration) {
+	if every <= 0 {
+		e.RuleDelD(event, RuleDigest)
+	} else {
+		e.RuleSetD(event, RuleDigest, every)
+	}
+}
+
+// FlushDigests removes and returns the digests whose window ended at or before now,
+// grouped by subscriber. Digests for subscribers that no longer exist are dropped.
+// Each namespace has its own digests; call this on every namespace that uses them.
+func (s *Subscribe) FlushDigests(now time.Time) []*DigestBatch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.digestMu.Lock()
+	defer s.digestMu.Unlock()
+
+	batches := []*DigestBatch{}
+	index := make(map[*Subscriber]*DigestBatch)
+	kept := make([]*Digest, 0, len(s.Digests))
+
+	for _, digest := range s.Digests {
+		if now.Before(digest.Start.Add(digest.Every)) {
+			kept = append(kept, digest)
+			continue
+		}
+
+		s.emit(&Change{Op: OpDigestFlush, Digest: &Digest{Subscriber: digest.Subscriber, Event: digest.Event}})
+
+		ref := digest.Subscriber
+
+		sub := s.findSubscriberLocked(ref.ID, ref.Contact, ref.API)
+		if sub == nil {
+			continue
+		}
+
+		if index[sub] == nil {
+			index[sub] = &DigestBatch{Subscriber: sub}
+			batches = append(batches, index[sub])
+		}
+
+		index[sub].Digests = append(index[sub].Digests, digest)
+	}
+
+	s.Digests = kept
+
+	return batches
+}
+
+// PendingDigests returns a copy of the digests that have not been flushed.
+func (s *Subscribe) PendingDigests() []*Digest {
+	return s.snapshotDigests()
+}
+
+// digestLocked returns true if the subscriber's subscription is a digest subscription.
+// If collect is true, the occurrence is also added to their digest. Call with mu held.
+func (s *Subscribe) digestLocked(sub *Subscriber, occurrence *Occurrence, collect bool) bool {
+	every, ok := sub.Events.RuleGetD(occurrence.Event, RuleDigest)
+	if !ok || every <= 0 {
+		return false
+	} else if !collect {
+		return true
+	}
+
+	item := *occurrence
+	if item.Time.IsZero() {
+		item.Time = time.Now()
+	}
+
+	record := &Digest{
+		Subscriber:  *sub.ref(),
+		Event:       sub.Events.Match(occurrence.Event),
+		Start:       item.Time,
+		Every:       every,
+		Occurrences: []*Occurrence{&item},
+	}
+
+	s.digestMu.Lock()
+	defer s.digestMu.Unlock()
+
+	s.addDigestLocked(record)
+	s.emit(&Change{Op: OpDigest, Digest: record})
+
+	return true
+}
+
+// addDigestLocked adds a digest's occurrences to the pending digest for the same
+// subscriber and subscription, or adds the digest. Call with digestMu held.
+func (s *Subscribe) addDigestLocked(record *Digest) {
+	idx := slices.IndexFunc(s.Digests, record.sameWindow)
+	if idx < 0 {
+		s.Digests = append(s.Digests, cloneDigest(record))
+		return
+	}
+
+	digest := s.Digests[idx]
+
+	for _, item := range record.Occurrences {
+		// Skip occurrences already in the digest, so journal replay is idempotent.
+		if !slices.ContainsFunc(digest.Occurrences, item.same) {
+			cloned := *item
+			digest.Occurrences = append(digest.Occurrences, &cloned)
+		}
+	}
+}
+
+// applyDigest replays a digest change.
+func (s *Subscribe) applyDigest(record *Change) {
+	if record.Digest == nil {
+		return
+	}
+
+	s.digestMu.Lock()
+	defer s.digestMu.Unlock()
+
+	if record.Op == OpDigest {
+		s.addDigestLocked(record.Digest)
+	} else {
+		s.Digests = slices.DeleteFunc(s.Digests, record.Digest.sameWindow)
+	}
+}
+
+func (s *Subscribe) snapshotDigests() []*Digest {
+	s.digestMu.Lock()
+	defer s.digestMu.Unlock()
+
+	if len(s.Digests) == 0 {
+		return nil
+	}
+
+	out := make([]*Digest, 0, len(s.Digests))
+	for _, digest := range s.Digests {
+		out = append(out, cloneDigest(digest))
+	}
+
+	return out
+}
+
+// sameWindow returns true if both digests are for the same subscriber and subscription.
+func (d *Digest) sameWindow(other *Digest) bool {
+	return d.Subscriber == other.Subscriber && d.Event == other.Event
+}
+
+// same returns true if both occurrences are the same record.
+func (o *Occurrence) same(other *Occurrence) bool {
+	return o.Event == other.Event && o.Severity == other.Severity && o.Time.Equal(other.Time) &&
+		reflect.DeepEqual(o.Attrs, other.Attrs)
+}
+
+func cloneDigest(digest *Digest) *Digest {
+	out := *digest
+	out.Occurrences = make([]*Occurrence, 0, len(digest.Occurrences))
+
+	for _, item := range digest.Occurrences {
+		cloned := *item
+		cloned.Attrs = maps.Clone(item.Attrs)
+		out.Occurrences = append(out.Occurrences, &cloned)
+	}
+
+	return &out
+}
@@ -0,0 +1,99 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigests(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	start := time.Now()
+
+	instant := sub.CreateSub("instant", "api", false, false)
+	digest := sub.CreateSub("digest", "api", false, false)
+
+	require.NoError(t, instant.Subscribe("camera.#"))
+	require.NoError(t, digest.Subscribe("camera.#"))
+	digest.Events.SetDigest("camera.#", 10*time.Minute)
+
+	assertions.Equal([]*Subscriber{instant}, sub.GetSubscribers("camera.front"))
+	assertions.Empty(sub.PendingDigests(), "looking up subscribers must not collect digests")
+
+	_, subs, err := sub.Dispatch(&Occurrence{Event: "camera.front", Time: start})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{instant}, subs)
+
+	_, subs, err = sub.Dispatch(&Occurrence{
+		Event: "camera.back", Attrs: map[string]any{"zone": "yard"}, Time: start.Add(time.Minute),
+	})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{instant}, subs)
+
+	pending := sub.PendingDigests()
+	require.Len(t, pending, 1)
+	assertions.Equal("camera.#", pending[0].Event)
+	require.Len(t, pending[0].Occurrences, 2)
+	assertions.Equal("camera.front", pending[0].Occurrences[0].Event)
+	assertions.Equal("yard", pending[0].Occurrences[1].Attrs["zone"])
+
+	assertions.Empty(sub.FlushDigests(start.Add(time.Minute)), "the window has not elapsed")
+
+	batches := sub.FlushDigests(start.Add(11 * time.Minute))
+	require.Len(t, batches, 1)
+	assertions.Same(digest, batches[0].Subscriber)
+	require.Len(t, batches[0].Digests, 1)
+	assertions.Len(batches[0].Digests[0].Occurrences, 2)
+	assertions.Empty(sub.PendingDigests())
+
+	digest.Events.SetDigest("camera.#", 0)
+	assertions.Equal([]*Subscriber{instant, digest}, sub.GetSubscribers("camera.front"))
+}
+
+func TestDigestsPersist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	user.Events.SetDigest("motion", time.Hour)
+
+	other := sub.CreateSub("other", "api", false, false)
+	require.NoError(t, other.Subscribe("motion"))
+	other.Events.SetDigest("motion", time.Minute)
+
+	for range 2 {
+		_, subs, err := sub.Dispatch(&Occurrence{Event: "motion"})
+		require.NoError(t, err)
+		assert.Empty(t, subs)
+	}
+	require.Len(t, sub.FlushDigests(time.Now().Add(2*time.Minute)), 1)
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+
+	pending := loaded.PendingDigests()
+	require.Len(t, pending, 1, "flushed digests must stay flushed")
+	assert.Equal(t, "user", pending[0].Subscriber.Contact)
+	assert.Len(t, pending[0].Occurrences, 2)
+
+	require.NoError(t, loaded.StateFileSave())
+
+	saved, err := GetDB(path)
+	require.NoError(t, err)
+
+	batches := saved.FlushDigests(time.Now().Add(2 * time.Hour))
+	require.Len(t, batches, 1)
+	assert.Equal(t, "user", batches[0].Subscriber.Contact)
+}
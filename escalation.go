@@ -0,0 +1,275 @@
+package subscribe
+
+import (
+	"crypto/rand"
+	"maps"
+	"slices"
+	"time"
+)
+
+/****************************
+ *    Escalation Methods    *
+ ****************************/
+
+// EscalationTarget picks who an escalation step notifies. Nobody is notified twice
+// for the same occurrence.
+type EscalationTarget string
+
+const (
+	// EscalateNext notifies the next subscriber for the event, in GetSubscribers order.
+	EscalateNext EscalationTarget = "next"
+	// EscalateSubscribers notifies every subscriber for the event.
+	EscalateSubscribers EscalationTarget = "subscribers"
+	// EscalateAdmins notifies every admin, like GetAdmins.
+	EscalateAdmins EscalationTarget = "admins"
+)
+
+// EscalationPolicy is a list of steps run in order until the occurrence is acknowledged,
+// or the last step has run.
+// Attach a policy to an event with DefineEvent.
+type EscalationPolicy struct {
+	Steps []*EscalationStep `json:"steps"`
+}
+
+// EscalationStep notifies a target once After has elapsed since the previous step
+// ran, or since the occurrence was escalated for the first step.
+type EscalationStep struct {
+	After  time.Duration    `json:"after"`
+	Target EscalationTarget `json:"target"`
+}
+
+// Escalation is an occurrence being escalated.
+type Escalation struct {
+	// ID identifies the occurrence for Acknowledge.
+	ID string `json:"id"`
+	// Occurrence is the escalated occurrence, with aliases and default severity resolved.
+	Occurrence *Occurrence `json:"occurrence"`
+	// Step is the number of steps that have run.
+	Step int `json:"step"`
+	// Last is when the last step was due, or when the occurrence was escalated.
+	Last time.Time `json:"last"`
+	// Notified is everyone notified so far.
+	Notified []*SubscriberRef `json:"notified,omitempty"`
+}
+
+// EscalationNotice is a step that ran, and who it notified.
+type EscalationNotice struct {
+	ID          string
+	Event       string
+	Step        int
+	Subscribers []*Subscriber
+}
+
+// Escalate starts escalating an occurrence of an event with an escalation policy, and runs
+// the steps that are due at the occurrence's time. Subscribers are found like
+// GetOccurrenceSubscribers, except digest subscriptions are skipped. Returns the ID to pass
+// to Acknowledge, and the notices for the steps that ran. Returns ErrNoEscalation if the
+// event has no policy, or ErrUnknownSeverity for an invalid severity.
+func (s *Subscribe) Escalate(occurrence *Occurrence) (string, []*EscalationNotice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event := s.resolveEventLocked(occurrence.Event)
+	if def := s.definitionLocked(event); def == nil || def.Escalation == nil || len(def.Escalation.Steps) == 0 {
+		return "", nil, ErrNoEscalation
+	}
+
+	if _, err := s.occurrenceSubscribersLocked(occurrence, lookupQuiet); err != nil {
+		return "", nil, err
+	}
+
+	esc := &Escalation{ID: rand.Text(), Occurrence: s.resolveOccurrenceLocked(occurrence)}
+	if esc.Occurrence.Time.IsZero() {
+		esc.Occurrence.Time = time.Now()
+	}
+
+	esc.Last = esc.Occurrence.Time
+
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	s.Escalations = append(s.Escalations, esc)
+	notices := s.runEscalationLocked(esc, esc.Last)
+	s.emit(&Change{Op: OpEscalate, Escalate: esc})
+	s.noteEscalationLocked(esc)
+
+	if s.escalationDoneLocked(esc) {
+		s.endEscalationLocked(esc.ID)
+	}
+
+	return esc.ID, notices, nil
+}
+
+// Tick runs the escalation steps that are due at now, and returns who to notify.
+// Call it regularly, like once a minute.
+func (s *Subscribe) Tick(now time.Time) []*EscalationNotice {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	notices := []*EscalationNotice{}
+	done := []string{}
+
+	for _, esc := range s.Escalations {
+		ran := s.runEscalationLocked(esc, now)
+		if len(ran) > 0 {
+			notices = append(notices, ran...)
+			s.emit(&Change{Op: OpEscalate, Escalate: esc})
+			s.noteEscalationLocked(esc)
+		}
+
+		if s.escalationDoneLocked(esc) {
+			done = append(done, esc.ID)
+		}
+	}
+
+	for _, id := range done {
+		s.endEscalationLocked(id)
+	}
+
+	return notices
+}
+
+// GetEscalations returns a copy of the occurrences being escalated. An escalation ends when
+// it is acknowledged, or once its last step has run. Its occurrence record stays in the
+// history, and may still be acknowledged, until it is pruned.
+func (s *Subscribe) GetEscalations() []*Escalation {
+	return s.snapshotEscalations()
+}
+
+// escalationDoneLocked returns true if every step of an escalation has run, or its event
+// no longer has a policy. Call with mu and escalationMu held.
+func (s *Subscribe) escalationDoneLocked(esc *Escalation) bool {
+	def := s.definitionLocked(esc.Occurrence.Event)
+
+	return def == nil || def.Escalation == nil || esc.Step >= len(def.Escalation.Steps)
+}
+
+// endEscalationLocked removes an escalation. Call with escalationMu held.
+func (s *Subscribe) endEscalationLocked(occurrenceID string) {
+	if idx := s.escalationLocked(occurrenceID); idx >= 0 {
+		s.Escalations = slices.Delete(s.Escalations, idx, idx+1)
+		s.emit(&Change{Op: OpEscalateEnd, Escalate: &Escalation{ID: occurrenceID}})
+	}
+}
+
+// runEscalationLocked runs the steps of an escalation that are due at now.
+// Call with mu and escalationMu held.
+func (s *Subscribe) runEscalationLocked(esc *Escalation, now time.Time) []*EscalationNotice {
+	def := s.definitionLocked(esc.Occurrence.Event)
+	if def == nil || def.Escalation == nil {
+		return nil
+	}
+
+	notices := []*EscalationNotice{}
+
+	for esc.Step < len(def.Escalation.Steps) {
+		step := def.Escalation.Steps[esc.Step]
+		due := esc.Last.Add(step.After)
+
+		if now.Before(due) {
+			break
+		}
+
+		esc.Step++
+		esc.Last = due
+		notices = append(notices, &EscalationNotice{
+			ID:          esc.ID,
+			Event:       esc.Occurrence.Event,
+			Step:        esc.Step - 1,
+			Subscribers: s.escalationTargetsLocked(esc, step.Target),
+		})
+	}
+
+	return notices
+}
+
+// escalationTargetsLocked returns the subscribers a step notifies, and records them as notified.
+func (s *Subscribe) escalationTargetsLocked(esc *Escalation, target EscalationTarget) []*Subscriber {
+	var candidates []*Subscriber
+
+	if target == EscalateAdmins {
+		candidates = s.getAdminsLocked()
+	} else {
+		// The severity was validated by Escalate.
+		candidates, _ = s.occurrenceSubscribersLocked(esc.Occurrence, lookupQuiet)
+	}
+
+	targets := []*Subscriber{}
+
+	for _, sub := range candidates {
+		if slices.ContainsFunc(esc.Notified, sub.matches) {
+			continue
+		}
+
+		targets = append(targets, sub)
+		esc.Notified = append(esc.Notified, sub.ref())
+
+		if target == EscalateNext {
+			break
+		}
+	}
+
+	return targets
+}
+
+// resolveOccurrenceLocked returns a copy of an occurrence with its alias and default severity resolved.
+func (s *Subscribe) resolveOccurrenceLocked(occurrence *Occurrence) *Occurrence {
+	out := *occurrence
+	out.Event = s.resolveEventLocked(occurrence.Event)
+
+	if def := s.definitionLocked(out.Event); out.Severity == "" && def != nil {
+		out.Severity = def.Severity
+	}
+
+	return &out
+}
+
+// applyEscalation replays an escalation change.
+func (s *Subscribe) applyEscalation(record *Change) {
+	if record.Escalate == nil {
+		return
+	}
+
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	idx := slices.IndexFunc(s.Escalations, func(esc *Escalation) bool { return esc.ID == record.Escalate.ID })
+
+	switch {
+	case record.Op == OpEscalateEnd && idx >= 0:
+		s.Escalations = slices.Delete(s.Escalations, idx, idx+1)
+	case record.Op == OpEscalate && record.Escalate.Occurrence != nil && idx >= 0:
+		s.Escalations[idx] = cloneEscalation(record.Escalate)
+	case record.Op == OpEscalate && record.Escalate.Occurrence != nil:
+		s.Escalations = append(s.Escalations, cloneEscalation(record.Escalate))
+	}
+}
+
+func (s *Subscribe) snapshotEscalations() []*Escalation {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	if len(s.Escalations) == 0 {
+		return nil
+	}
+
+	out := make([]*Escalation, 0, len(s.Escalations))
+	for _, esc := range s.Escalations {
+		out = append(out, cloneEscalation(esc))
+	}
+
+	return out
+}
+
+func cloneEscalation(esc *Escalation) *Escalation {
+	out := *esc
+	occurrence := *esc.Occurrence
+	occurrence.Attrs = maps.Clone(esc.Occurrence.Attrs)
+	out.Occurrence = &occurrence
+	out.Notified = cloneRefs(esc.Notified)
+
+	return &out
+}
@@ -0,0 +1,147 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalation(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	start := time.Now()
+
+	primary := sub.CreateSub("primary", "api", false, false)
+	secondary := sub.CreateSub("secondary", "api", false, false)
+	admin := sub.CreateSub("admin", "api", true, false)
+	outsider := sub.CreateSub("outsider", "api", false, false)
+
+	require.NoError(t, primary.Subscribe("alert"))
+	require.NoError(t, secondary.Subscribe("alert"))
+
+	_, _, err := sub.Escalate(&Occurrence{Event: "alert"})
+	require.ErrorIs(t, err, ErrNoEscalation)
+
+	policy := &EscalationPolicy{Steps: []*EscalationStep{
+		{Target: EscalateNext},
+		{After: 5 * time.Minute, Target: EscalateNext},
+		{After: 10 * time.Minute, Target: EscalateAdmins},
+	}}
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "alert", Escalation: policy}))
+
+	id, notices, err := sub.Escalate(&Occurrence{Event: "alert", Time: start})
+	require.NoError(t, err)
+	require.Len(t, notices, 1, "the first step must run right away")
+	assertions.Equal([]*Subscriber{primary}, notices[0].Subscribers)
+
+	assertions.Empty(sub.Tick(start.Add(4 * time.Minute)))
+
+	notices = sub.Tick(start.Add(5 * time.Minute))
+	require.Len(t, notices, 1)
+	assertions.Equal(id, notices[0].ID)
+	assertions.Equal(1, notices[0].Step)
+	assertions.Equal([]*Subscriber{secondary}, notices[0].Subscribers)
+
+	require.ErrorIs(t, sub.Acknowledge(id, outsider), ErrNotNotified)
+	require.ErrorIs(t, sub.Acknowledge("missing", primary), ErrOccurrenceNotFound)
+
+	notices = sub.Tick(start.Add(time.Hour))
+	require.Len(t, notices, 1)
+	assertions.Equal([]*Subscriber{admin}, notices[0].Subscribers)
+	assertions.Empty(sub.GetEscalations(), "the escalation must end after its last step")
+	assertions.Empty(sub.Tick(start.Add(2*time.Hour)), "every step has run")
+
+	require.NoError(t, sub.Acknowledge(id, secondary), "an ended escalation may still be acknowledged")
+	assertions.Empty(sub.GetEscalations())
+
+	// The occurrence record outlives the escalation, so a late acknowledgement is recorded without restarting it.
+	require.NoError(t, sub.Acknowledge(id, primary), "acknowledging a resolved occurrence is recorded in the history")
+	assertions.Empty(sub.GetEscalations())
+	assertions.Empty(sub.Tick(start.Add(3 * time.Hour)))
+
+	rec, err := sub.GetOccurrence(id)
+	require.NoError(t, err)
+	require.Len(t, rec.Responses, 2)
+	assertions.Equal("primary", rec.Responses[1].Subscriber.Contact)
+
+	// Once the record is pruned the occurrence is unknown again.
+	assertions.Equal(1, sub.PruneOccurrences(start.Add(time.Second)))
+	require.ErrorIs(t, sub.Acknowledge(id, secondary), ErrOccurrenceNotFound)
+
+	// An acknowledged occurrence does not escalate.
+	id, _, err = sub.Escalate(&Occurrence{Event: "alert", Time: start})
+	require.NoError(t, err)
+	require.NoError(t, sub.Acknowledge(id, admin), "admins may always acknowledge")
+	assertions.Empty(sub.Tick(start.Add(time.Hour)))
+}
+
+func TestEscalationPersist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	start := time.Now()
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("alert"))
+	sub.CreateSub("admin", "api", true, false)
+	policy := &EscalationPolicy{Steps: []*EscalationStep{
+		{Target: EscalateSubscribers},
+		{After: time.Minute, Target: EscalateAdmins},
+	}}
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "alert", Escalation: policy}))
+
+	id, _, err := sub.Escalate(&Occurrence{Event: "alert", Time: start})
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, loaded.JournalEnable(0))
+
+	escalations := loaded.GetEscalations()
+	require.Len(t, escalations, 1)
+	assert.Equal(t, id, escalations[0].ID)
+	assert.Equal(t, 1, escalations[0].Step)
+
+	notices := loaded.Tick(start.Add(time.Minute))
+	require.Len(t, notices, 1)
+	require.Len(t, notices[0].Subscribers, 1)
+	assert.Equal(t, "admin", notices[0].Subscribers[0].Contact)
+
+	require.NoError(t, loaded.StateFileSave())
+	require.NoError(t, loaded.JournalDisable())
+
+	saved, err := GetDB(path)
+	require.NoError(t, err)
+	assert.Empty(t, saved.GetEscalations(), "the ended escalation must stay ended")
+
+	rec, err := saved.GetOccurrence(id)
+	require.NoError(t, err)
+	assert.Len(t, rec.Notified, 2, "the record must keep everyone the escalation notified")
+}
+
+func TestEscalationSingleStep(t *testing.T) {
+	t.Parallel()
+
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("alert"))
+	policy := &EscalationPolicy{Steps: []*EscalationStep{
+		{Target: EscalateSubscribers},
+	}}
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "alert", Escalation: policy}))
+
+	id, notices, err := sub.Escalate(&Occurrence{Event: "alert"})
+	require.NoError(t, err)
+	require.Len(t, notices, 1)
+	assert.Empty(t, sub.GetEscalations(), "a policy whose only step ran must not leave an escalation")
+	require.NoError(t, sub.Acknowledge(id, user))
+}
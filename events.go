@@ -58,6 +58,8 @@ func (e *Events) Exists(event string) bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; ok {
 		return true
 	}
@@ -70,11 +72,14 @@ func (e *Events) New(event string, rules *Rules) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; ok {
 		return ErrEventExists
 	}
 
 	e.Map[event] = cloneRules(rules)
+	e.emitLocked(&Change{Op: OpNew, Event: event, Rules: e.Map[event]})
 
 	return nil
 }
@@ -88,39 +93,49 @@ func (e *Events) UnPause(event string) error {
 // Pause (or unpause with 0 duration) a subscriber's event subscription.
 // Returns an error only if the event subscription is not found.
 func (e *Events) Pause(event string, duration time.Duration) error {
+	return e.PauseUntil(event, time.Now().Add(duration))
+}
+
+// PauseUntil pauses a subscriber's event subscription until a specific time.
+// Returns an error only if the event subscription is not found.
+func (e *Events) PauseUntil(event string, until time.Time) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok {
 		return ErrEventNotFound
 	}
 
-	e.Map[event].Pause = time.Now().Add(duration)
+	e.Map[event].Pause = until
+	e.emitLocked(&Change{Op: OpPause, Event: event, Pause: until})
 
 	return nil
 }
 
 // IsPaused returns true if the event's notifications are paused.
 // Returns true if the event subscription does not exist.
+// Uses the subscription chosen by Match, so patterns apply.
 func (e *Events) IsPaused(event string) bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	info, ok := e.Map[event]
-	if !ok {
+	_, info := e.matchLocked(event)
+	if info == nil {
 		return true
 	}
 
 	return info.Pause.After(time.Now())
 }
 
-// PauseTime returns the pause time for an event.
+// PauseTime returns the pause time for an event, using the subscription chosen by Match.
 func (e *Events) PauseTime(event string) time.Time {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	info, ok := e.Map[event]
-	if !ok {
+	_, info := e.matchLocked(event)
+	if info == nil {
 		return time.Time{}
 	}
 
@@ -132,16 +147,23 @@ func (e *Events) Remove(event string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
+	if _, ok := e.Map[event]; !ok {
+		return
+	}
+
 	delete(e.Map, event)
+	e.emitLocked(&Change{Op: OpRemove, Event: event})
 }
 
-// RuleGetD returns a Duration rule.
+// RuleGetD returns a Duration rule, using the subscription chosen by Match.
 func (e *Events) RuleGetD(event, rule string) (time.Duration, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	rules, found := e.Map[event]
-	if !found || rules == nil {
+	_, rules := e.matchLocked(event)
+	if rules == nil {
 		return 0, false
 	}
 
@@ -150,13 +172,13 @@ func (e *Events) RuleGetD(event, rule string) (time.Duration, bool) {
 	return val, found
 }
 
-// RuleGetI returns an integer rule.
+// RuleGetI returns an integer rule, using the subscription chosen by Match.
 func (e *Events) RuleGetI(event, rule string) (int, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	rules, found := e.Map[event]
-	if !found || rules == nil {
+	_, rules := e.matchLocked(event)
+	if rules == nil {
 		return 0, false
 	}
 
@@ -165,13 +187,13 @@ func (e *Events) RuleGetI(event, rule string) (int, bool) {
 	return val, found
 }
 
-// RuleGetS returns a string rule.
+// RuleGetS returns a string rule, using the subscription chosen by Match.
 func (e *Events) RuleGetS(event, rule string) (string, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	rules, found := e.Map[event]
-	if !found || rules == nil {
+	_, rules := e.matchLocked(event)
+	if rules == nil {
 		return "", false
 	}
 
@@ -180,13 +202,13 @@ func (e *Events) RuleGetS(event, rule string) (string, bool) {
 	return val, found
 }
 
-// RuleGetT returns a Time rule.
+// RuleGetT returns a Time rule, using the subscription chosen by Match.
 func (e *Events) RuleGetT(event, rule string) (time.Time, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	rules, found := e.Map[event]
-	if !found || rules == nil {
+	_, rules := e.matchLocked(event)
+	if rules == nil {
 		return time.Now(), false
 	}
 
@@ -200,6 +222,8 @@ func (e *Events) RuleSetD(event, rule string, val time.Duration) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok {
 		return
 	}
@@ -209,6 +233,10 @@ func (e *Events) RuleSetD(event, rule string, val time.Duration) {
 	}
 
 	e.Map[event].D[rule] = val
+	e.emitLocked(&Change{
+		Op: OpRuleSet, Event: event, Kind: "D", Rule: rule,
+		Rules: &Rules{D: map[string]time.Duration{rule: val}},
+	})
 }
 
 // RuleSetI updates or sets an integer rule.
@@ -216,6 +244,8 @@ func (e *Events) RuleSetI(event, rule string, val int) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok {
 		return
 	}
@@ -225,6 +255,10 @@ func (e *Events) RuleSetI(event, rule string, val int) {
 	}
 
 	e.Map[event].I[rule] = val
+	e.emitLocked(&Change{
+		Op: OpRuleSet, Event: event, Kind: "I", Rule: rule,
+		Rules: &Rules{I: map[string]int{rule: val}},
+	})
 }
 
 // RuleSetS updates or sets a string rule.
@@ -232,6 +266,8 @@ func (e *Events) RuleSetS(event, rule, val string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok {
 		return
 	}
@@ -241,6 +277,10 @@ func (e *Events) RuleSetS(event, rule, val string) {
 	}
 
 	e.Map[event].S[rule] = val
+	e.emitLocked(&Change{
+		Op: OpRuleSet, Event: event, Kind: "S", Rule: rule,
+		Rules: &Rules{S: map[string]string{rule: val}},
+	})
 }
 
 // RuleSetT updates or sets a Time rule.
@@ -248,6 +288,8 @@ func (e *Events) RuleSetT(event, rule string, val time.Time) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok {
 		return
 	}
@@ -257,6 +299,10 @@ func (e *Events) RuleSetT(event, rule string, val time.Time) {
 	}
 
 	e.Map[event].T[rule] = val
+	e.emitLocked(&Change{
+		Op: OpRuleSet, Event: event, Kind: "T", Rule: rule,
+		Rules: &Rules{T: map[string]time.Time{rule: val}},
+	})
 }
 
 // RuleDelD deletes a Duration rule.
@@ -264,11 +310,14 @@ func (e *Events) RuleDelD(event, rule string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok || e.Map[event].D == nil {
 		return
 	}
 
 	delete(e.Map[event].D, rule)
+	e.emitLocked(&Change{Op: OpRuleDel, Event: event, Kind: "D", Rule: rule})
 }
 
 // RuleDelI deletes an integer rule.
@@ -276,11 +325,14 @@ func (e *Events) RuleDelI(event, rule string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok || e.Map[event].I == nil {
 		return
 	}
 
 	delete(e.Map[event].I, rule)
+	e.emitLocked(&Change{Op: OpRuleDel, Event: event, Kind: "I", Rule: rule})
 }
 
 // RuleDelS deletes a string rule.
@@ -288,11 +340,14 @@ func (e *Events) RuleDelS(event, rule string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok || e.Map[event].S == nil {
 		return
 	}
 
 	delete(e.Map[event].S, rule)
+	e.emitLocked(&Change{Op: OpRuleDel, Event: event, Kind: "S", Rule: rule})
 }
 
 // RuleDelT deletes a Time rule.
@@ -300,11 +355,14 @@ func (e *Events) RuleDelT(event, rule string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok || e.Map[event].T == nil {
 		return
 	}
 
 	delete(e.Map[event].T, rule)
+	e.emitLocked(&Change{Op: OpRuleDel, Event: event, Kind: "T", Rule: rule})
 }
 
 // RuleDelAll deletes rules of any type with a specific name.
@@ -312,6 +370,8 @@ func (e *Events) RuleDelAll(event, rule string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	event = e.keyLocked(event)
+
 	if _, ok := e.Map[event]; !ok {
 		return
 	}
@@ -320,6 +380,47 @@ func (e *Events) RuleDelAll(event, rule string) {
 	delete(e.Map[event].I, rule)
 	delete(e.Map[event].S, rule)
 	delete(e.Map[event].T, rule)
+	e.emitLocked(&Change{Op: OpRuleDel, Event: event, Rule: rule})
+}
+
+// key returns the name an event is stored under. See keyLocked.
+func (e *Events) key(event string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.keyLocked(event)
+}
+
+// keyLocked returns the name an event is stored under. This is the event itself,
+// unless case folding is enabled and the event is stored with different case.
+// Call with mu held.
+func (e *Events) keyLocked(event string) string {
+	if _, ok := e.Map[event]; ok || !e.fold {
+		return event
+	}
+
+	key := event
+
+	// Pick the first match in sorted order, in case duplicates predate case folding.
+	for k := range e.Map {
+		if strings.EqualFold(k, event) && (key == event || k < key) {
+			key = k
+		}
+	}
+
+	return key
+}
+
+// emitLocked hands a change to the attached consumer, if any. Call with mu held.
+// Every change that adds or removes a name from Map comes through here.
+func (e *Events) emitLocked(c *Change) {
+	if c.Op == OpNew || c.Op == OpRemove || c.Op == OpRename {
+		e.patterns.Store(nil)
+	}
+
+	if e.notify != nil {
+		e.notify(c)
+	}
 }
 
 func cloneRules(rules *Rules) *Rules {
@@ -0,0 +1,98 @@
+package subscribe
+
+import (
+	"time"
+)
+
+/*************************
+ *    Explain Methods    *
+ *************************/
+
+// Decision explains whether GetSubscribers would return a subscriber for an event.
+type Decision struct {
+	Subscriber SubscriberRef `json:"subscriber"`
+	// Event is the event checked, after resolving aliases.
+	Event string `json:"event"`
+	// Notified is true if GetSubscribers would return the subscriber.
+	Notified bool `json:"notified"`
+	// Reason is the first check that failed. It is empty when Notified is true.
+	Reason Reason `json:"reason,omitempty"`
+	// Via is the group that notifies the subscriber, when they have no subscription of their own.
+	Via string `json:"via,omitempty"`
+	// Ignored is the subscriber's Ignored flag.
+	Ignored bool `json:"ignored"`
+	// APIAllowed is true if the subscriber's API passes EnableAPIs.
+	APIAllowed bool `json:"apiAllowed"`
+	// APIMatch is the EnableAPIs entry that allowed the API. It is empty if EnableAPIs is empty.
+	APIMatch string `json:"apiMatch,omitempty"`
+	// EnableAPIs is the list the API was checked against.
+	EnableAPIs []string `json:"enableApis"`
+	// Subscription is the subscriber's subscription that matches the event, which may
+	// be a pattern. It is empty if they are not subscribed.
+	Subscription string `json:"subscription,omitempty"`
+	// PausedUntil is the subscription's pause time, if it is paused.
+	PausedUntil time.Time `json:"pausedUntil,omitzero"`
+	// Digest is true if the subscription collects occurrences in a digest.
+	Digest bool `json:"digest,omitempty"`
+	// Groups are the subscriber's groups that are subscribed to the event.
+	Groups []*GroupDecision `json:"groups,omitempty"`
+}
+
+// GroupDecision explains a group subscription that matches the event.
+type GroupDecision struct {
+	Group        string    `json:"group"`
+	Subscription string    `json:"subscription"`
+	PausedUntil  time.Time `json:"pausedUntil,omitzero"`
+}
+
+// Explain reports each check GetSubscribers makes for a subscriber and an event,
+// and whether the subscriber would be returned. Nothing is changed.
+func (s *Subscribe) Explain(sub *Subscriber, event string) *Decision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	decision := &Decision{
+		Subscriber: *sub.ref(),
+		Event:      s.resolveEventLocked(event),
+		Ignored:    sub.Ignored,
+		EnableAPIs: append([]string{}, s.EnableAPIs...),
+	}
+
+	decision.APIMatch, decision.APIAllowed = s.matchAPILocked(sub.API)
+	decision.Subscription = sub.Events.Match(decision.Event)
+
+	if pause := sub.Events.PauseTime(decision.Event); decision.Subscription != "" && pause.After(now) {
+		decision.PausedUntil = pause
+	}
+
+	decision.Digest = s.digestLocked(sub, &Occurrence{Event: decision.Event}, false)
+	decision.Reason = s.exclusionLocked(sub, &Occurrence{Event: decision.Event}, nil, false)
+	decision.Notified = decision.Reason == ""
+
+	for _, name := range s.groupNamesLocked() {
+		group := s.Groups[name]
+		if !group.HasMember(sub) {
+			continue
+		}
+
+		subscription := group.Events.Match(decision.Event)
+		if subscription == "" {
+			continue
+		}
+
+		groupDecision := &GroupDecision{Group: name, Subscription: subscription}
+		if pause := group.Events.PauseTime(decision.Event); pause.After(now) {
+			groupDecision.PausedUntil = pause
+		}
+
+		decision.Groups = append(decision.Groups, groupDecision)
+
+		// Groups only notify members without a subscription of their own; see groupSubscribersLocked.
+		if decision.Reason == ReasonNotSubscribed && groupDecision.PausedUntil.IsZero() && decision.Via == "" {
+			decision.Notified, decision.Reason, decision.Via = true, "", name
+		}
+	}
+
+	return decision
+}
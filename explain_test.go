@@ -0,0 +1,78 @@
+package subscribe
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}, EnableAPIs: []string{"push"}}
+
+	notified := sub.CreateSub("notified", "pushover", false, false)
+	ignored := sub.CreateSub("ignored", "pushover", false, true)
+	disabled := sub.CreateSub("disabled", "email", false, false)
+	paused := sub.CreateSub("paused", "pushover", false, false)
+	missing := sub.CreateSub("missing", "pushover", false, false)
+	member := sub.CreateSub("member", "pushover", false, false)
+	digest := sub.CreateSub("digest", "pushover", false, false)
+
+	for _, s := range []*Subscriber{notified, ignored, disabled, paused, digest} {
+		require.NoError(t, s.Subscribe("camera.#"))
+	}
+
+	pause := time.Now().Add(time.Hour)
+	require.NoError(t, paused.Events.PauseUntil("camera.#", pause))
+	digest.Events.SetDigest("camera.#", time.Hour)
+
+	group := sub.CreateGroup("oncall")
+	group.AddMember(member)
+	group.AddMember(paused)
+	require.NoError(t, group.Subscribe("camera.motion"))
+	sub.EventAlias("motion", "camera.motion")
+
+	decision := sub.Explain(notified, "motion")
+	assertions.True(decision.Notified)
+	assertions.Empty(decision.Reason)
+	assertions.Equal("camera.motion", decision.Event, "aliases must be resolved")
+	assertions.Equal("camera.#", decision.Subscription)
+	assertions.Equal("push", decision.APIMatch)
+	assertions.True(decision.APIAllowed)
+
+	assertions.Equal(ReasonIgnored, sub.Explain(ignored, "motion").Reason)
+
+	decision = sub.Explain(disabled, "motion")
+	assertions.Equal(ReasonAPIDisabled, decision.Reason)
+	assertions.False(decision.APIAllowed)
+	assertions.Equal([]string{"push"}, decision.EnableAPIs)
+
+	decision = sub.Explain(paused, "motion")
+	assertions.Equal(ReasonPaused, decision.Reason)
+	assertions.Equal(pause, decision.PausedUntil)
+	require.Len(t, decision.Groups, 1)
+	assertions.False(decision.Notified, "a paused subscription wins over a group")
+
+	decision = sub.Explain(missing, "motion")
+	assertions.Equal(ReasonNotSubscribed, decision.Reason)
+	assertions.Empty(decision.Subscription)
+
+	decision = sub.Explain(member, "motion")
+	assertions.True(decision.Notified)
+	assertions.Equal("oncall", decision.Via)
+
+	decision = sub.Explain(digest, "motion")
+	assertions.Equal(ReasonDigest, decision.Reason)
+	assertions.True(decision.Digest)
+	assertions.Empty(sub.PendingDigests(), "explaining must not collect digests")
+
+	subs := sub.GetSubscribers("motion")
+	for _, s := range sub.Subscribers {
+		assertions.Equal(slices.Contains(subs, s), sub.Explain(s, "motion").Notified, s.Contact)
+	}
+}
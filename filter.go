@@ -0,0 +1,579 @@
+package subscribe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/************************
+ *    Filter Methods    *
+ ************************/
+
+// RuleFilter is the string rule holding a subscription's filter expression. Set it with SetFilter.
+//
+// A filter is evaluated against the attributes passed to GetSubscribersFor. It compares
+// attributes to strings, numbers and booleans with == != < <= > >=, and combines
+// comparisons with && || ! and parentheses, like: camera == "front" && confidence > 80.
+// Attribute names may contain letters, digits, underscores and dots. An attribute used
+// alone is true if it is set and not false, 0 or "". Missing attributes are never equal
+// to, less than, or greater than anything. GetSubscribers has no attributes to check,
+// so it skips subscriptions with a filter.
+const RuleFilter = "filter"
+
+// SubscribeWithFilter adds an event subscription with a filter expression.
+// Returns an error if the subscription already exists or the filter is invalid.
+func (s *Subscriber) SubscribeWithFilter(event, filter string) error {
+	if err := ValidateFilter(filter); err != nil {
+		return err
+	}
+
+	if err := s.Subscribe(event); err != nil {
+		return err
+	}
+
+	return s.Events.SetFilter(event, filter)
+}
+
+// SetFilter sets, or with an empty filter removes, an event subscription's filter expression.
+// Returns an error wrapping ErrInvalidFilter if the filter cannot be parsed.
+func (e *Events) SetFilter(event, filter string) error {
+	if filter == "" {
+		e.RuleDelS(event, RuleFilter)
+		e.filters.Clear()
+
+		return nil
+	}
+
+	node, err := parseFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	e.RuleSetS(event, RuleFilter, filter)
+	// Drop the filters this one may have replaced.
+	e.filters.Clear()
+	e.filters.Store(filter, &parsedFilter{node: node})
+
+	return nil
+}
+
+// ValidateFilter returns an error wrapping ErrInvalidFilter if a filter expression cannot be parsed.
+func ValidateFilter(filter string) error {
+	_, err := parseFilter(filter)
+
+	return err
+}
+
+// GetSubscribersFor works like GetSubscribers, and also skips subscribers, and groups, whose
+// subscription has a filter the attributes do not pass. A filter that cannot be parsed, like one
+// set with RuleSetS instead of SetFilter, never passes.
+func (s *Subscribe) GetSubscribersFor(eventName string, attrs map[string]any) []*Subscriber {
+	return s.GetSubscribersForContext(context.Background(), eventName, attrs)
+}
+
+// GetSubscribersForContext works like GetSubscribersFor, in a span of ctx's trace. See SetTracer.
+func (s *Subscribe) GetSubscribersForContext(
+	ctx context.Context, eventName string, attrs map[string]any,
+) []*Subscriber {
+	subscribers, _ := s.traceSubscribers(ctx, "GetSubscribersFor", eventName, func() ([]*Subscriber, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		event := s.resolveEventLocked(eventName)
+
+		return s.subscribersLocked(&Occurrence{Event: event, Attrs: attrs}, filterAccept(event, attrs), lookupQuery), nil
+	})
+
+	return subscribers
+}
+
+// unfilteredAccept returns an accept function for subscribersLocked that skips subscriptions with a filter.
+func unfilteredAccept(eventName string) func(*Events) bool {
+	return func(events *Events) bool {
+		filter, _ := events.RuleGetS(eventName, RuleFilter)
+
+		return filter == ""
+	}
+}
+
+// filterAccept returns an accept function for subscribersLocked that evaluates subscription filters.
+func filterAccept(eventName string, attrs map[string]any) func(*Events) bool {
+	return func(events *Events) bool {
+		filter, _ := events.RuleGetS(eventName, RuleFilter)
+		if filter == "" {
+			return true
+		}
+
+		node, err := events.parsedFilter(filter)
+
+		return err == nil && truthy(node.eval(attrs))
+	}
+}
+
+// parsedFilter is the result of parsing a filter expression.
+type parsedFilter struct {
+	node filterNode
+	err  error
+}
+
+// parsedFilter returns a filter expression's parse result, parsing it only the first time.
+func (e *Events) parsedFilter(filter string) (filterNode, error) {
+	if cached, ok := e.filters.Load(filter); ok {
+		if parsed, ok := cached.(*parsedFilter); ok {
+			return parsed.node, parsed.err
+		}
+	}
+
+	node, err := parseFilter(filter)
+	e.filters.Store(filter, &parsedFilter{node: node, err: err})
+
+	return node, err
+}
+
+/* The expression language. */
+
+// filterNode is a parsed filter expression.
+type filterNode interface {
+	eval(attrs map[string]any) any
+}
+
+type (
+	filterLiteral struct{ val any }
+	filterAttr    struct{ name string }
+	filterNot     struct{ node filterNode }
+	filterAnd     struct{ left, right filterNode }
+	filterOr      struct{ left, right filterNode }
+	filterCompare struct {
+		op          string
+		left, right filterNode
+	}
+)
+
+func (f *filterLiteral) eval(map[string]any) any {
+	return f.val
+}
+
+func (f *filterAttr) eval(attrs map[string]any) any {
+	return attrs[f.name]
+}
+
+func (f *filterNot) eval(attrs map[string]any) any {
+	return !truthy(f.node.eval(attrs))
+}
+
+func (f *filterAnd) eval(attrs map[string]any) any {
+	return truthy(f.left.eval(attrs)) && truthy(f.right.eval(attrs))
+}
+
+func (f *filterOr) eval(attrs map[string]any) any {
+	return truthy(f.left.eval(attrs)) || truthy(f.right.eval(attrs))
+}
+
+func (f *filterCompare) eval(attrs map[string]any) any {
+	return compareValues(f.op, f.left.eval(attrs), f.right.eval(attrs))
+}
+
+// truthy converts an attribute or expression value to a bool.
+func truthy(val any) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+
+	if num, ok := toFloat(val); ok {
+		return num != 0
+	}
+
+	return true
+}
+
+// compareValues compares two numbers, two strings or two bools. Anything else is only not-equal.
+func compareValues(op string, left, right any) bool {
+	var cmp int
+
+	leftNum, leftOK := toFloat(left)
+	rightNum, rightOK := toFloat(right)
+	leftStr, leftIsStr := left.(string)
+	rightStr, rightIsStr := right.(string)
+	leftBool, leftIsBool := left.(bool)
+	rightBool, rightIsBool := right.(bool)
+
+	switch {
+	case leftOK && rightOK:
+		cmp = compareOrdered(leftNum, rightNum)
+	case leftIsStr && rightIsStr:
+		cmp = strings.Compare(leftStr, rightStr)
+	case leftIsBool && rightIsBool && (op == "==" || op == "!="):
+		return (leftBool == rightBool) == (op == "==")
+	default:
+		return op == "!="
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+func compareOrdered(left, right float64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// toFloat converts any Go number to a float64.
+func toFloat(val any) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+/* The parser. */
+
+type filterTokenKind int
+
+const (
+	tokenEOF filterTokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	val  any
+	pos  int
+}
+
+// filterOperators are matched longest first.
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// parseFilter parses a filter expression.
+func parseFilter(filter string) (filterNode, error) {
+	tokens, err := lexFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &filterParser{tokens: tokens}
+
+	node, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := parser.peek(); tok.kind != tokenEOF {
+		return nil, tok.unexpected()
+	}
+
+	return node, nil
+}
+
+func lexFilter(filter string) ([]*filterToken, error) {
+	tokens := []*filterToken{}
+
+	for pos := 0; pos < len(filter); {
+		char, size := utf8.DecodeRuneInString(filter[pos:])
+
+		switch {
+		case unicode.IsSpace(char):
+			pos += size
+		case char == '"':
+			// Quotes and backslashes are never part of a multi-byte rune, so bytes work here.
+			end := pos + 1
+			for end < len(filter) && filter[end] != '"' {
+				if filter[end] == '\\' {
+					end++
+				}
+
+				end++
+			}
+
+			if end >= len(filter) {
+				return nil, fmt.Errorf("%w: position %d: unterminated string", ErrInvalidFilter, pos)
+			}
+
+			val, err := strconv.Unquote(filter[pos : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: position %d: %w", ErrInvalidFilter, pos, err)
+			}
+
+			tokens = append(tokens, &filterToken{kind: tokenString, text: filter[pos : end+1], val: val, pos: pos})
+			pos = end + 1
+		case char == '-' || char == '.' || unicode.IsDigit(char):
+			end := scanRunes(filter, pos+size, func(char rune) bool { return char == '.' || unicode.IsDigit(char) })
+
+			val, err := strconv.ParseFloat(filter[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: position %d: invalid number %q", ErrInvalidFilter, pos, filter[pos:end])
+			}
+
+			tokens = append(tokens, &filterToken{kind: tokenNumber, text: filter[pos:end], val: val, pos: pos})
+			pos = end
+		case char == '_' || unicode.IsLetter(char):
+			end := scanRunes(filter, pos+size, isIdentChar)
+			tokens = append(tokens, &filterToken{kind: tokenIdent, text: filter[pos:end], pos: pos})
+			pos = end
+		default:
+			tok := lexOperator(filter, pos)
+			if tok == nil {
+				return nil, fmt.Errorf("%w: position %d: unexpected %q", ErrInvalidFilter, pos, char)
+			}
+
+			tokens = append(tokens, tok)
+			pos += len(tok.text)
+		}
+	}
+
+	return append(tokens, &filterToken{kind: tokenEOF, pos: len(filter)}), nil
+}
+
+// scanRunes returns the position after the runes from pos on that match.
+func scanRunes(filter string, pos int, match func(rune) bool) int {
+	for pos < len(filter) {
+		char, size := utf8.DecodeRuneInString(filter[pos:])
+		if !match(char) {
+			break
+		}
+
+		pos += size
+	}
+
+	return pos
+}
+
+func lexOperator(filter string, pos int) *filterToken {
+	for _, op := range filterOperators {
+		if strings.HasPrefix(filter[pos:], op) {
+			return &filterToken{kind: tokenOperator, text: op, pos: pos}
+		}
+	}
+
+	return nil
+}
+
+func isIdentChar(char rune) bool {
+	return char == '_' || char == '.' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+func (t *filterToken) unexpected() error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("%w: unexpected end of expression", ErrInvalidFilter)
+	}
+
+	return fmt.Errorf("%w: position %d: unexpected %q", ErrInvalidFilter, t.pos, t.text)
+}
+
+// maxFilterDepth limits how deeply parentheses and ! may nest in a filter expression.
+const maxFilterDepth = 32
+
+// filterParser is a recursive descent parser. From lowest to highest precedence: || && ! comparisons.
+type filterParser struct {
+	tokens []*filterToken
+	pos    int
+	depth  int
+}
+
+// nest counts a level of nesting, and returns an error if there are too many.
+// Call the returned function when leaving the level.
+func (p *filterParser) nest() (func(), error) {
+	if p.depth++; p.depth > maxFilterDepth {
+		return nil, fmt.Errorf("%w: nested more than %d levels", ErrInvalidFilter, maxFilterDepth)
+	}
+
+	return func() { p.depth-- }, nil
+}
+
+func (p *filterParser) peek() *filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() *filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+// accept consumes the next token if it is one of the operators.
+func (p *filterParser) accept(ops ...string) (string, bool) {
+	if tok := p.peek(); tok.kind == tokenOperator {
+		for _, op := range ops {
+			if tok.text == op {
+				p.pos++
+				return op, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &filterOr{left: left, right: right}
+	}
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &filterAnd{left: left, right: right}
+	}
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if _, ok := p.accept("!"); ok {
+		leave, err := p.nest()
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &filterNot{node: node}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return &filterCompare{op: op, left: left, right: right}, nil
+}
+
+func (p *filterParser) parseOperand() (filterNode, error) {
+	if _, ok := p.accept("("); ok {
+		leave, err := p.nest()
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.peek().unexpected()
+		}
+
+		return node, nil
+	}
+
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenString, tokenNumber:
+		return &filterLiteral{val: tok.val}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &filterLiteral{val: true}, nil
+		case "false":
+			return &filterLiteral{val: false}, nil
+		default:
+			return &filterAttr{name: tok.text}, nil
+		}
+	default:
+		return nil, tok.unexpected()
+	}
+}
@@ -0,0 +1,125 @@
+package subscribe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterExpressions(t *testing.T) {
+	t.Parallel()
+
+	attrs := map[string]any{
+		"camera":      "front",
+		"confidence":  85,
+		"temperature": -2.5,
+		"armed":       true,
+		"zone.name":   "porch",
+		"empty":       "",
+		"température": -2,
+		"caméra":      "façade",
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`camera == "front"`, true},
+		{`camera != "front"`, false},
+		{`camera == "front" && confidence > 80`, true},
+		{`camera == "front" && confidence > 90`, false},
+		{`camera == "back" || confidence >= 85`, true},
+		{`!(camera == "back")`, true},
+		{`temperature < 0`, true},
+		{`temperature <= -2.5 && confidence < 100.5`, true},
+		{`armed`, true},
+		{`armed == false`, false},
+		{`!empty`, true},
+		{`zone.name == "porch"`, true},
+		{`missing == 1`, false},
+		{`missing != 1`, true},
+		{`missing < 1 || missing > 1`, false},
+		{`camera > 1`, false},
+		{`"a" < "b"`, true},
+		{`camera == "fr\"ont"`, false},
+		{`1 == 1 && (2 > 3 || !false)`, true},
+		{`température < 0 && caméra == "façade"`, true},
+		{strings.Repeat("(", maxFilterDepth) + "armed" + strings.Repeat(")", maxFilterDepth), true},
+	}
+
+	for _, test := range tests {
+		node, err := parseFilter(test.filter)
+		require.NoError(t, err, test.filter)
+		assert.Equal(t, test.match, truthy(node.eval(attrs)), test.filter)
+	}
+
+	for _, filter := range []string{
+		``, `camera ==`, `(a == 1`, `a == 1)`, `a = 1`, `"open`, `a == 1.2.3`, `a & b`, `a == ✓`,
+		strings.Repeat("(", maxFilterDepth+1) + "a" + strings.Repeat(")", maxFilterDepth+1),
+		strings.Repeat("!", maxFilterDepth+1) + "a",
+	} {
+		require.ErrorIs(t, ValidateFilter(filter), ErrInvalidFilter, filter)
+	}
+}
+
+func TestGetSubscribersFor(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+
+	front := sub.CreateSub("front", "api", false, false)
+	anything := sub.CreateSub("anything", "api", false, false)
+	member := sub.CreateSub("member", "api", false, false)
+
+	require.ErrorIs(t, front.SubscribeWithFilter("motion", `camera ==`), ErrInvalidFilter)
+	assertions.False(front.Events.Exists("motion"), "an invalid filter must not subscribe")
+
+	require.NoError(t, front.SubscribeWithFilter("motion", `camera == "front" && confidence > 80`))
+	require.NoError(t, anything.Subscribe("motion"))
+
+	group := sub.CreateGroup("oncall")
+	group.AddMember(member)
+	require.NoError(t, group.Subscribe("motion"))
+	require.NoError(t, group.Events.SetFilter("motion", `confidence > 50`))
+
+	filter, _ := front.Events.RuleGetS("motion", RuleFilter)
+	assertions.Equal(`camera == "front" && confidence > 80`, filter, "the filter must be stored in the rules")
+
+	_, parsed := front.Events.filters.Load(filter)
+	assertions.True(parsed, "the filter must be parsed once, when it is set")
+
+	assertions.Equal([]*Subscriber{front, anything, member},
+		sub.GetSubscribersFor("motion", map[string]any{"camera": "front", "confidence": 90}))
+	assertions.Equal([]*Subscriber{anything, member},
+		sub.GetSubscribersFor("motion", map[string]any{"camera": "back", "confidence": 90}))
+	assertions.Equal([]*Subscriber{anything}, sub.GetSubscribersFor("motion", nil))
+	assertions.Equal([]*Subscriber{anything}, sub.GetSubscribers("motion"),
+		"GetSubscribers has no attributes, so it must skip filtered subscriptions")
+
+	require.NoError(t, front.Events.SetFilter("motion", `!muted`))
+	assertions.Equal([]*Subscriber{front, anything}, sub.GetSubscribersFor("motion", nil))
+	assertions.Equal([]*Subscriber{anything}, sub.GetSubscribers("motion"),
+		"a filter must be skipped even if it passes without attributes")
+	require.NoError(t, front.Events.SetFilter("motion", `camera == "front" && confidence > 80`))
+
+	require.ErrorIs(t, group.Events.SetFilter("motion", `>`), ErrInvalidFilter)
+	require.NoError(t, group.Events.SetFilter("motion", ""))
+	assertions.Equal([]*Subscriber{anything, member}, sub.GetSubscribersFor("motion", nil))
+
+	sub.SetSeverities("low", "high")
+	require.NoError(t, sub.SetMinSeverity(anything.Events, "motion", "high"))
+
+	subs, err := sub.GetOccurrenceSubscribers(&Occurrence{
+		Event: "motion", Severity: "low", Attrs: map[string]any{"camera": "front", "confidence": 90},
+	})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{front, member}, subs, "severity and filters must both apply")
+
+	front.Events.RuleSetS("motion", RuleFilter, `camera ==`)
+	assertions.Equal([]*Subscriber{anything, member},
+		sub.GetSubscribersFor("motion", map[string]any{"camera": "front", "confidence": 90}),
+		"a filter that cannot be parsed must not pass")
+}
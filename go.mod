@@ -4,7 +4,10 @@ go 1.25.6
 
 toolchain go1.26.0
 
-require github.com/stretchr/testify v1.11.1
+require (
+	github.com/stretchr/testify v1.11.1
+	golang.org/x/sys v0.45.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,232 @@
+package subscribe
+
+import (
+	"slices"
+	"sort"
+)
+
+/***********************
+ *    Group Methods    *
+ ***********************/
+
+// CreateGroup creates a subscriber group, or returns the existing group with this name.
+func (s *Subscribe) CreateGroup(name string) *Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if group, ok := s.Groups[name]; ok {
+		return group
+	}
+
+	if s.Groups == nil {
+		s.Groups = make(map[string]*Group)
+	}
+
+	group := &Group{
+		Name:    name,
+		Members: make([]*SubscriberRef, 0),
+		Events:  &Events{Map: make(map[string]*Rules)},
+		owner:   s,
+	}
+	s.Groups[name] = group
+	s.attachGroupHook(group)
+	s.emit(&Change{Op: OpGroup, Group: name})
+
+	return group
+}
+
+// GetGroup returns a subscriber group by name.
+func (s *Subscribe) GetGroup(name string) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if group, ok := s.Groups[name]; ok {
+		return group, nil
+	}
+
+	return nil, ErrGroupNotFound
+}
+
+// GroupNames returns the names of all subscriber groups, sorted.
+func (s *Subscribe) GroupNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.groupNamesLocked()
+}
+
+func (s *Subscribe) groupNamesLocked() []string {
+	names := make([]string, 0, len(s.Groups))
+
+	for name := range s.Groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// GroupRemove deletes a subscriber group and its subscriptions. The members are not affected.
+func (s *Subscribe) GroupRemove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.Groups[name]; !ok {
+		return
+	}
+
+	delete(s.Groups, name)
+	s.emit(&Change{Op: OpGroupRemove, Group: name})
+}
+
+// Subscribe adds an event subscription to a group. Every member is notified
+// of the event through GetSubscribers, unless the group's subscription is paused.
+// The subscription starts with the event definition's default rules, if any.
+// Returns an error only if the event subscription already exists.
+func (g *Group) Subscribe(event string) error {
+	return g.Events.subscribe(event)
+}
+
+// AddMember adds a subscriber to the group. Does nothing if they are already a member.
+func (g *Group) AddMember(sub *Subscriber) {
+	g.addMember(sub.ref())
+}
+
+// RemoveMember removes a subscriber from the group.
+func (g *Group) RemoveMember(sub *Subscriber) {
+	g.removeMember(sub.ref())
+}
+
+// HasMember returns true if the subscriber is a member of the group.
+func (g *Group) HasMember(sub *Subscriber) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return slices.ContainsFunc(g.Members, sub.matches)
+}
+
+func (g *Group) addMember(ref *SubscriberRef) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, member := range g.Members {
+		if *member == *ref {
+			return
+		}
+	}
+
+	g.Members = append(g.Members, ref)
+	g.emitLocked(&Change{Op: OpGroupMember, Sub: ref})
+}
+
+func (g *Group) removeMember(ref *SubscriberRef) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	before := len(g.Members)
+	g.Members = slices.DeleteFunc(g.Members, func(member *SubscriberRef) bool { return *member == *ref })
+
+	if len(g.Members) != before {
+		g.emitLocked(&Change{Op: OpGroupMemberRemove, Sub: ref})
+	}
+}
+
+// emitLocked records a group change. Call with mu held.
+func (g *Group) emitLocked(c *Change) {
+	if g.owner != nil {
+		c.Group = g.Name
+		g.owner.emit(c)
+	}
+}
+
+// members returns a copy of the member list.
+func (g *Group) members() []*SubscriberRef {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return slices.Clone(g.Members)
+}
+
+// groupSubscribersLocked returns the members of every group subscribed to (and not paused for)
+// an event, skipping subscribers in seen. Members are filtered like GetSubscribers, and a member
+// with their own subscription matching the event is skipped: if it is paused, that pause wins.
+// If accept is not nil, it must return true for the group's Events. Call with mu held.
+func (s *Subscribe) groupSubscribersLocked(
+	eventName string, seen map[*Subscriber]bool, accept func(*Events) bool,
+) []*Subscriber {
+	subscribers := []*Subscriber{}
+
+	for _, name := range s.groupNamesLocked() {
+		group := s.Groups[name]
+		if group.Events.IsPaused(eventName) || (accept != nil && !accept(group.Events)) {
+			continue
+		}
+
+		for _, ref := range group.members() {
+			sub := s.findSubscriberLocked(ref.ID, ref.Contact, ref.API)
+			if sub == nil || seen[sub] || sub.Ignored || !s.checkAPILocked(sub.API) {
+				continue
+			}
+
+			if sub.Events.Match(eventName) != "" {
+				continue
+			}
+
+			seen[sub] = true
+			subscribers = append(subscribers, sub)
+		}
+	}
+
+	return subscribers
+}
+
+// attachGroupHook points a group's Events map at emit.
+func (s *Subscribe) attachGroupHook(group *Group) {
+	group.Events.mu.Lock()
+	defer group.Events.mu.Unlock()
+
+	group.Events.notify = func(c *Change) {
+		c.Group = group.Name
+		s.emit(c)
+	}
+	group.Events.defaults = s.defaultRules
+	group.Events.fold = s.caseFolding()
+}
+
+// setGroupsLocked replaces the groups with freshly loaded ones. Call with mu held.
+func (s *Subscribe) setGroupsLocked(groups map[string]*Group) {
+	s.Groups = groups
+
+	for _, group := range groups {
+		group.owner = s
+	}
+}
+
+// applyGroup replays a single group change.
+func (s *Subscribe) applyGroup(record *Change) {
+	switch record.Op {
+	case OpGroup:
+		s.CreateGroup(record.Group)
+	case OpGroupRemove:
+		s.GroupRemove(record.Group)
+	case OpGroupMember:
+		if record.Sub != nil {
+			s.CreateGroup(record.Group).addMember(record.Sub)
+		}
+	case OpGroupMemberRemove:
+		if record.Sub != nil {
+			s.CreateGroup(record.Group).removeMember(record.Sub)
+		}
+	default:
+		s.CreateGroup(record.Group).Events.applyChange(record)
+	}
+}
+
+func snapshotGroup(group *Group) *Group {
+	return &Group{
+		Name:    group.Name,
+		Members: group.members(),
+		Events:  snapshotEvents(group.Events),
+	}
+}
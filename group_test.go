@@ -0,0 +1,103 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupGetSubscribers(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: new(Events)}
+
+	direct := sub.CreateSub("direct", "api", false, false)
+	member := sub.CreateSub("member", "api", false, false)
+	paused := sub.CreateSub("paused", "api", false, false)
+	ignored := sub.CreateSub("ignored", "api", false, true)
+	other := sub.CreateSub("other", "other-api", false, false)
+
+	require.NoError(t, direct.Subscribe("evt"))
+	require.NoError(t, paused.Subscribe("evt"))
+	require.NoError(t, paused.Events.Pause("evt", time.Hour))
+
+	group := sub.CreateGroup("oncall")
+	assertions.Same(group, sub.CreateGroup("oncall"))
+	require.NoError(t, group.Subscribe("evt"))
+
+	for _, s := range []*Subscriber{direct, member, paused, ignored, other, member} {
+		group.AddMember(s)
+	}
+
+	assertions.Len(group.Members, 5, "members must not be added twice")
+	assertions.True(group.HasMember(member))
+
+	sub.EnableAPIs = []string{"api"}
+	subs := sub.GetSubscribers("evt")
+	assertions.Equal([]*Subscriber{direct, member}, subs,
+		"direct subscribers come first, then group members, each once")
+
+	require.NoError(t, group.Events.Pause("evt", time.Hour))
+	assertions.Equal([]*Subscriber{direct}, sub.GetSubscribers("evt"), "a paused group must be skipped")
+
+	require.NoError(t, group.Events.UnPause("evt"))
+	group.RemoveMember(member)
+	assertions.False(group.HasMember(member))
+	assertions.Equal([]*Subscriber{direct}, sub.GetSubscribers("evt"))
+
+	sub.EventRemove("evt")
+	assertions.False(group.Events.Exists("evt"))
+
+	assertions.Equal([]string{"oncall"}, sub.GroupNames())
+	sub.GroupRemove("oncall")
+	sub.GroupRemove("oncall")
+
+	_, err := sub.GetGroup("oncall")
+	require.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestGroupPersistence(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	stateFile := filepath.Join(t.TempDir(), "groups.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+
+	member := sub.CreateSubWithID(9, "member", "api", false, false)
+	group := sub.CreateGroup("team")
+	group.AddMember(member)
+	require.NoError(t, group.Subscribe("evt"))
+	require.NoError(t, sub.StateFileSave())
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err)
+
+	got, err := loaded.GetGroup("team")
+	require.NoError(t, err)
+	assertions.Equal([]*SubscriberRef{{ID: 9, Contact: "member", API: "api"}}, got.Members)
+	assertions.Len(loaded.GetSubscribers("evt"), 1)
+
+	// Group changes are journaled too.
+	require.NoError(t, sub.JournalEnable(0))
+	group.RemoveMember(member)
+	group.Events.RuleSetS("evt", "channel", "#ops")
+	sub.CreateGroup("new").AddMember(member)
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err = GetDB(stateFile)
+	require.NoError(t, err)
+	assertions.Equal([]string{"new", "team"}, loaded.GroupNames())
+
+	got, err = loaded.GetGroup("team")
+	require.NoError(t, err)
+	assertions.Empty(got.Members)
+
+	channel, _ := got.Events.RuleGetS("evt", "channel")
+	assertions.Equal("#ops", channel)
+}
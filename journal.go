@@ -0,0 +1,633 @@
+package subscribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+/*************************
+ *    Journal Methods    *
+ *************************/
+
+// Change operations. These are written to journals and stores; do not change the values.
+const (
+	OpSubscriber  = "subscriber"
+	OpUnsubscribe = "subscriberRemove"
+	OpNew         = "new"
+	OpPause       = "pause"
+	OpRemove      = "remove"
+	OpRuleSet     = "ruleSet"
+	OpRuleDel     = "ruleDel"
+	OpRename      = "rename"
+	OpAlias       = "alias"
+	OpAliasRemove = "aliasRemove"
+	OpDefine      = "define"
+	OpUndefine    = "undefine"
+	OpSeverities  = "severities"
+	OpDigest      = "digest"
+	OpDigestFlush = "digestFlush"
+	OpEscalate    = "escalate"
+	OpEscalateEnd = "escalateEnd"
+	OpOccurrence  = "occurrence"
+	OpPrune       = "occurrenceRemove"
+	// These records carry only a namespace.
+	OpNamespace       = "namespace"
+	OpNamespaceRemove = "namespaceRemove"
+	// These records carry a group name.
+	OpGroup             = "group"
+	OpGroupRemove       = "groupRemove"
+	OpGroupMember       = "groupMember"
+	OpGroupMemberRemove = "groupMemberRemove"
+)
+
+const (
+	// DefaultJournalCompact is the number of journal records written before
+	// the journal is compacted into the state file, when none is provided.
+	DefaultJournalCompact = 1000
+	// journalSuffix is appended to the state file path to name the journal.
+	journalSuffix = ".journal"
+	// journalOldSuffix names a journal that was rotated out but not yet compacted.
+	journalOldSuffix = ".journal.old"
+)
+
+// journal appends changes to a log file next to the state file.
+type journal struct {
+	mu        sync.Mutex
+	stateFile string
+	file      *os.File
+	records   int
+	compactAt int
+	compact   bool
+	closed    bool
+	err       error
+	wg        sync.WaitGroup
+}
+
+// JournalEnable turns on journaled persistence. Every mutation made through the library
+// (CreateSub, CreateSubWithID, Events.New, Subscribe, Pause, Rule* and Remove methods) is
+// appended to a log file next to the state file. Once compactEvery records are written the
+// journal is compacted into the state file in the background. StateFileSave also compacts.
+// Changes made directly to struct fields (like Meta and EnableAPIs) are not journaled;
+// they are persisted by the next compaction. Use a value <= 0 for DefaultJournalCompact.
+// Returns ErrStoreInUse if the database was opened with GetStoreDB; stores persist every change.
+func (s *Subscribe) JournalEnable(compactEvery int) error {
+	return s.journalEnable(context.Background(), compactEvery)
+}
+
+func (s *Subscribe) journalEnable(ctx context.Context, compactEvery int) error {
+	if s.parent != nil {
+		return s.parent.journalEnable(ctx, compactEvery)
+	}
+
+	if s.getStore() != nil {
+		return ErrStoreInUse
+	}
+
+	if compactEvery <= 0 {
+		compactEvery = DefaultJournalCompact
+	}
+
+	s.mu.RLock()
+	stateFile := s.stateFile
+	s.mu.RUnlock()
+
+	if stateFile == "" {
+		return ErrNoStateFile
+	}
+
+	if err := s.JournalDisable(); err != nil {
+		return err
+	}
+
+	// The database in memory already includes any journal left on disk (StateFileLoad
+	// replays it), so this compaction persists it and starts an empty journal.
+	j := &journal{stateFile: stateFile, compactAt: compactEvery}
+
+	if err := s.compactJournal(ctx, j); err != nil {
+		return err
+	}
+
+	s.hookMu.Lock()
+	s.journal = j
+	s.hookMu.Unlock()
+
+	s.attachHooks()
+
+	return nil
+}
+
+// JournalDisable stops journaling and closes the journal file.
+// Pending records remain on disk and are replayed by the next StateFileLoad.
+func (s *Subscribe) JournalDisable() error {
+	if s.parent != nil {
+		return s.parent.JournalDisable()
+	}
+
+	s.hookMu.Lock()
+	j := s.journal
+	s.journal = nil
+	s.hookMu.Unlock()
+
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	j.closed = true
+	j.mu.Unlock()
+	j.wg.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+
+	if err := j.file.Close(); err != nil {
+		return fmt.Errorf("closing journal: %w", err)
+	}
+
+	return nil
+}
+
+// JournalCompact writes the whole database to the state file and empties the journal.
+// Does nothing if journaling is not enabled.
+func (s *Subscribe) JournalCompact() error {
+	if s.parent != nil {
+		return s.parent.JournalCompact()
+	}
+
+	s.hookMu.RLock()
+	j := s.journal
+	s.hookMu.RUnlock()
+
+	if j == nil {
+		return nil
+	}
+
+	return s.compactJournal(context.Background(), j)
+}
+
+// compactJournal rotates the journal out, writes a snapshot, then deletes the rotated journal.
+// Records written after the rotation land in the new journal. Replaying them over a snapshot
+// that already contains them is harmless, because every record sets a value outright.
+func (s *Subscribe) compactJournal(ctx context.Context, j *journal) error {
+	j.mu.Lock()
+	j.err = nil
+	j.mu.Unlock()
+
+	err := s.lockStateFile(ctx, j.stateFile, func() error {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+
+		buf, err := s.marshalState()
+		if err != nil {
+			return err
+		}
+
+		if err = s.writeStateFileLocked(j.stateFile, buf); err != nil {
+			return err
+		}
+
+		err = os.Remove(j.stateFile + journalOldSuffix)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing compacted journal: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		// A failed compaction is retried on the next journal write.
+		j.mu.Lock()
+		j.err = err
+		j.mu.Unlock()
+	}
+
+	return err
+}
+
+// rotate moves the current journal aside and opens a new, empty one.
+func (j *journal) rotate() error {
+	const journalMode = 0o600
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file != nil {
+		if err := j.file.Close(); err != nil {
+			return fmt.Errorf("closing journal: %w", err)
+		}
+
+		j.file = nil
+	}
+
+	if err := appendFile(j.stateFile+journalOldSuffix, j.stateFile+journalSuffix); err != nil {
+		return err
+	}
+
+	if j.closed {
+		return nil
+	}
+
+	// #nosec G304 -- journal path is derived from the user-configured state file.
+	file, err := os.OpenFile(j.stateFile+journalSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, journalMode)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+
+	j.file = file
+	j.records = 0
+
+	return nil
+}
+
+// appendFile moves src onto the end of dst. A previous compaction may have left dst behind.
+func appendFile(dst, src string) error {
+	const journalMode = 0o600
+
+	// #nosec G304 -- journal path is derived from the user-configured state file.
+	buf, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+
+	if err = trimTornRecord(dst); err != nil {
+		return err
+	}
+
+	// #nosec G304 -- journal path is derived from the user-configured state file.
+	file, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_APPEND, journalMode)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+
+	_, err = file.Write(buf)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("rotating journal: %w", err)
+	}
+
+	if err = os.Remove(src); err != nil {
+		return fmt.Errorf("rotating journal: %w", err)
+	}
+
+	return nil
+}
+
+// trimTornRecord truncates a torn record from the end of a journal file, so more records
+// can be appended after it. Replay only ignores a torn record at the end of a file.
+func trimTornRecord(path string) error {
+	// #nosec G304 -- journal path is derived from the user-configured state file.
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+
+	keep := bytes.LastIndexByte(buf, '\n') + 1
+	if keep == len(buf) {
+		return nil
+	}
+
+	if err = os.Truncate(path, int64(keep)); err != nil {
+		return fmt.Errorf("truncating journal: %w", err)
+	}
+
+	return nil
+}
+
+// write appends one record to the journal. Returns true when it's time to compact.
+func (j *journal) write(c *Change) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed || j.file == nil {
+		return false
+	}
+
+	buf, err := json.Marshal(c)
+	if err == nil {
+		_, err = j.file.Write(append(buf, '\n'))
+	}
+
+	if err != nil {
+		// The change is in memory but not on disk. Compacting persists it.
+		j.err = fmt.Errorf("writing journal: %w", err)
+	} else {
+		j.records++
+	}
+
+	if j.compact || (j.records < j.compactAt && j.err == nil) {
+		return false
+	}
+
+	j.compact = true
+	j.wg.Add(1)
+
+	return true
+}
+
+// emit sends a change to every enabled consumer. Namespaces forward changes to their parent,
+// unless they were removed from it.
+func (s *Subscribe) emit(c *Change) {
+	if s.parent != nil {
+		if s.detached.Load() {
+			return
+		}
+
+		c.Namespace = s.namespace
+		s.parent.emit(c)
+
+		return
+	}
+
+	s.logChange(c)
+
+	s.hookMu.RLock()
+	j, store := s.journal, s.store
+	s.hookMu.RUnlock()
+
+	if store != nil {
+		s.storeWrite(store, c)
+	}
+
+	if j == nil || !j.write(c) {
+		return
+	}
+
+	// This runs while the caller holds locks the snapshot needs, so compact in the background.
+	go func() {
+		defer j.wg.Done()
+
+		_ = s.compactJournal(context.Background(), j)
+
+		j.mu.Lock()
+		j.compact = false
+		j.mu.Unlock()
+	}()
+}
+
+// attachHooks points every Events map at emit, so their changes are recorded.
+func (s *Subscribe) attachHooks() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.Events != nil {
+		s.Events.mu.Lock()
+		s.Events.notify = s.emit
+		s.Events.fold = s.caseFolding()
+		s.Events.mu.Unlock()
+	}
+
+	for _, sub := range s.Subscribers {
+		s.attachSubscriberHook(sub)
+	}
+
+	for _, group := range s.Groups {
+		s.attachGroupHook(group)
+	}
+
+	for _, ns := range s.Namespaces {
+		ns.attachHooks()
+	}
+}
+
+// attachSubscriberHook points a subscriber's Events map at emit.
+func (s *Subscribe) attachSubscriberHook(sub *Subscriber) {
+	if sub == nil || sub.Events == nil {
+		return
+	}
+
+	sub.Events.mu.Lock()
+	defer sub.Events.mu.Unlock()
+
+	sub.Events.notify = func(c *Change) {
+		c.Sub = sub.ref()
+		s.emit(c)
+	}
+	sub.Events.defaults = s.defaultRules
+	sub.Events.fold = s.caseFolding()
+}
+
+// removeJournal deletes the journal files for a state file. Call with the state file locked.
+func removeJournal(stateFile string) error {
+	for _, path := range []string{stateFile + journalOldSuffix, stateFile + journalSuffix} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing journal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// readJournal returns the contents of the journal files that exist, oldest first.
+// Call with the state file locked.
+func readJournal(stateFile string) ([][]byte, error) {
+	journals := [][]byte{}
+
+	for _, path := range []string{stateFile + journalOldSuffix, stateFile + journalSuffix} {
+		// #nosec G304 -- journal path is derived from the user-configured state file.
+		buf, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading journal: %w", err)
+		}
+
+		journals = append(journals, buf)
+	}
+
+	return journals, nil
+}
+
+// replay applies the records in buf. A torn (undecodable) final record is ignored;
+// it is the write that was in progress when the process stopped.
+func (s *Subscribe) replay(buf []byte) error {
+	lines := bytes.Split(buf, []byte("\n"))
+
+	for idx, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		record := &Change{}
+
+		if err := json.Unmarshal(line, record); err != nil {
+			if idx == len(lines)-1 {
+				return nil
+			}
+
+			return fmt.Errorf("%w: record %d: %w", ErrJournalCorrupt, idx+1, err)
+		}
+
+		s.apply(record)
+	}
+
+	return nil
+}
+
+// apply replays a single change. Records that no longer apply are skipped.
+func (s *Subscribe) apply(record *Change) {
+	if record.Namespace != "" && s.parent == nil {
+		switch record.Op {
+		case OpNamespace:
+			s.Namespace(record.Namespace)
+		case OpNamespaceRemove:
+			s.NamespaceRemove(record.Namespace)
+		default:
+			s.Namespace(record.Namespace).apply(record)
+		}
+
+		return
+	}
+
+	if record.Group != "" {
+		s.applyGroup(record)
+
+		return
+	}
+
+	if record.Op == OpSubscriber {
+		if record.Sub == nil {
+			return
+		}
+
+		if record.Sub.ID != 0 {
+			s.CreateSubWithID(record.Sub.ID, record.Sub.Contact, record.Sub.API, record.Admin, record.Ignored)
+		} else {
+			s.CreateSub(record.Sub.Contact, record.Sub.API, record.Admin, record.Ignored)
+		}
+
+		return
+	}
+
+	if record.Op == OpUnsubscribe {
+		s.removeSubscribers(func(sub *Subscriber) bool { return sub.matches(record.Sub) })
+
+		return
+	}
+
+	if record.Op == OpAlias || record.Op == OpAliasRemove {
+		s.applyAlias(record)
+
+		return
+	}
+
+	if record.Op == OpDefine || record.Op == OpUndefine {
+		s.applyDefinition(record)
+
+		return
+	}
+
+	if record.Op == OpDigest || record.Op == OpDigestFlush {
+		s.applyDigest(record)
+
+		return
+	}
+
+	if record.Op == OpEscalate || record.Op == OpEscalateEnd {
+		s.applyEscalation(record)
+
+		return
+	}
+
+	if record.Op == OpOccurrence || record.Op == OpPrune {
+		s.applyOccurrence(record)
+
+		return
+	}
+
+	if record.Op == OpSeverities {
+		s.SetSeverities(record.Levels...)
+
+		return
+	}
+
+	events := s.Events
+
+	if record.Sub != nil {
+		var (
+			sub *Subscriber
+			err error
+		)
+
+		if record.Sub.ID != 0 {
+			sub, err = s.GetSubscriberByID(record.Sub.ID, record.Sub.API)
+		} else {
+			sub, err = s.GetSubscriber(record.Sub.Contact, record.Sub.API)
+		}
+
+		if err != nil {
+			return
+		}
+
+		events = sub.Events
+	}
+
+	events.applyChange(record)
+}
+
+// applyChange replays a single change onto an Events map.
+func (e *Events) applyChange(record *Change) {
+	switch record.Op {
+	case OpNew:
+		// With case folding, an existing event keeps the case it is stored with.
+		event := e.key(record.Event)
+		e.Remove(event)
+		_ = e.New(event, record.Rules)
+	case OpPause:
+		_ = e.PauseUntil(record.Event, record.Pause)
+	case OpRemove:
+		e.Remove(record.Event)
+	case OpRename:
+		e.rename(record.Event, record.To)
+	case OpRuleSet:
+		e.applyRuleSet(record)
+	case OpRuleDel:
+		e.applyRuleDel(record)
+	}
+}
+
+func (e *Events) applyRuleSet(record *Change) {
+	if record.Rules == nil {
+		return
+	}
+
+	switch record.Kind {
+	case "D":
+		e.RuleSetD(record.Event, record.Rule, record.Rules.D[record.Rule])
+	case "I":
+		e.RuleSetI(record.Event, record.Rule, record.Rules.I[record.Rule])
+	case "S":
+		e.RuleSetS(record.Event, record.Rule, record.Rules.S[record.Rule])
+	case "T":
+		e.RuleSetT(record.Event, record.Rule, record.Rules.T[record.Rule])
+	}
+}
+
+func (e *Events) applyRuleDel(record *Change) {
+	switch record.Kind {
+	case "D":
+		e.RuleDelD(record.Event, record.Rule)
+	case "I":
+		e.RuleDelI(record.Event, record.Rule)
+	case "S":
+		e.RuleDelS(record.Event, record.Rule)
+	case "T":
+		e.RuleDelT(record.Event, record.Rule)
+	case "":
+		e.RuleDelAll(record.Event, record.Rule)
+	}
+}
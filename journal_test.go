@@ -0,0 +1,205 @@
+package subscribe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalReplay(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	stateFile := filepath.Join(t.TempDir(), "journal.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	require.NoError(t, sub.Events.New("global", nil))
+	sub.Events.RuleSetS("global", "color", "blue")
+
+	first := sub.CreateSub("contact", "api", true, false)
+	require.NoError(t, first.Subscribe("evt"))
+	require.NoError(t, first.Subscribe("gone"))
+	require.NoError(t, first.Events.Pause("evt", time.Hour))
+	first.Events.RuleSetD("evt", "d", time.Minute)
+	first.Events.RuleSetI("evt", "i", 3)
+	first.Events.RuleSetS("evt", "s", "str")
+	first.Events.RuleSetT("evt", "t", time.Unix(1700000000, 0))
+	first.Events.RuleSetI("evt", "dropped", 1)
+	first.Events.RuleDelI("evt", "dropped")
+	first.Events.Remove("gone")
+
+	second := sub.CreateSubWithID(42, "other", "api", false, true)
+	require.NoError(t, second.Subscribe("evt"))
+
+	// Nothing is compacted yet, so the state file is still empty.
+	// #nosec G304 -- test controls this temporary file path.
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	assertions.JSONEq(`{"enabledApis":[],"events":{"eventsMap":{}},"subscribers":[]}`, string(data))
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err)
+
+	want, err := sub.StateGetJSON()
+	require.NoError(t, err)
+	got, err := loaded.StateGetJSON()
+	require.NoError(t, err)
+	assertions.JSONEq(want, got, "replaying the journal must restore every change")
+
+	replayed, err := loaded.GetSubscriberByID(42, "api")
+	require.NoError(t, err)
+	assertions.True(replayed.Ignored)
+	assertions.True(loaded.Subscribers[0].Events.IsPaused("evt"))
+	assertions.False(loaded.Subscribers[0].Events.Exists("gone"))
+}
+
+func TestJournalTornRecord(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "torn.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+	sub.CreateSub("contact", "api", false, false)
+	require.NoError(t, sub.JournalDisable())
+
+	file, err := os.OpenFile(stateFile+journalSuffix, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"subscriber","sub":{"contact":"torn","ap`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err, "a torn final record must be ignored")
+	assert.Len(t, loaded.Subscribers, 1)
+
+	// Enabling the journal compacts it, which removes the torn record.
+	require.NoError(t, loaded.JournalEnable(0))
+	loaded.CreateSub("after", "api", false, false)
+	require.NoError(t, loaded.JournalDisable())
+
+	loaded, err = GetDB(stateFile)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Subscribers, 2)
+}
+
+func TestJournalCorruptRecord(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "corrupt.json")
+	journal := "not json\n" + `{"op":"subscriber","sub":{"contact":"c","api":"a"}}` + "\n"
+	require.NoError(t, os.WriteFile(stateFile+journalSuffix, []byte(journal), 0o600))
+
+	_, err := GetDB(stateFile)
+	require.ErrorIs(t, err, ErrJournalCorrupt)
+}
+
+func TestJournalCompact(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	stateFile := filepath.Join(t.TempDir(), "compact.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(3))
+
+	subscriber := sub.CreateSub("contact", "api", false, false)
+	require.NoError(t, subscriber.Subscribe("one"))
+	require.NoError(t, subscriber.Subscribe("two"))
+	// The disable waits for the background compaction triggered by the third record.
+	require.NoError(t, sub.JournalDisable())
+
+	// #nosec G304 -- test controls this temporary file path.
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	assertions.Contains(string(data), `"two"`, "the state file must contain compacted records")
+
+	require.NoError(t, sub.JournalEnable(0))
+	require.NoError(t, subscriber.Subscribe("three"))
+	require.NoError(t, sub.StateFileSave(), "saving must compact the journal")
+
+	info, err := os.Stat(stateFile + journalSuffix)
+	require.NoError(t, err)
+	assertions.Zero(info.Size(), "the journal must be empty after compaction")
+	assertions.NoFileExists(stateFile + journalOldSuffix)
+	require.NoError(t, sub.JournalCompact())
+	require.NoError(t, sub.JournalDisable())
+	require.NoError(t, sub.JournalCompact(), "compacting without a journal does nothing")
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err)
+	assertions.Equal([]string{"one", "three", "two"}, loaded.Subscribers[0].Events.Names())
+}
+
+func TestJournalNoStateFile(t *testing.T) {
+	t.Parallel()
+
+	sub, err := GetDB("")
+	require.NoError(t, err)
+	require.ErrorIs(t, sub.JournalEnable(0), ErrNoStateFile)
+}
+
+func TestJournalRelocate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sub, err := GetDB(filepath.Join(dir, "first.json"))
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	newPath := filepath.Join(dir, "second.json")
+	require.NoError(t, sub.StateFileRelocate(newPath))
+	sub.CreateSub("contact", "api", false, false)
+	require.NoError(t, sub.JournalDisable())
+
+	assert.FileExists(t, newPath+journalSuffix)
+
+	loaded, err := GetDB(newPath)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Subscribers, 1)
+}
+
+func TestJournalRotateTornRecord(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "rotate-torn.json")
+	rotated := `{"op":"subscriber","sub":{"contact":"old","api":"api"}}` + "\n" + `{"op":"subscriber","sub":{"con`
+	current := `{"op":"subscriber","sub":{"contact":"new","api":"api"}}` + "\n"
+
+	require.NoError(t, os.WriteFile(stateFile+journalOldSuffix, []byte(rotated), 0o600))
+	require.NoError(t, os.WriteFile(stateFile+journalSuffix, []byte(current), 0o600))
+	require.NoError(t, appendFile(stateFile+journalOldSuffix, stateFile+journalSuffix))
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err, "a torn record must not end up in the middle of the rotated journal")
+	assert.Len(t, loaded.Subscribers, 2)
+}
+
+func TestJournalStaleAfterSave(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "stale.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+	require.NoError(t, sub.Events.New("evt", nil))
+	require.NoError(t, sub.JournalEnable(0))
+	require.NoError(t, sub.Events.Pause("evt", time.Hour))
+	require.NoError(t, sub.JournalDisable())
+	require.NoError(t, sub.Events.UnPause("evt"))
+	require.NoError(t, sub.StateFileSave())
+
+	assert.NoFileExists(t, stateFile+journalSuffix, "saving without a journal must remove the old journal")
+	require.NoError(t, sub.StateFileLoad())
+	assert.False(t, sub.Events.IsPaused("evt"), "an old journal must not be replayed over a newer save")
+}
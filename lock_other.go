@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package subscribe
+
+import "fmt"
+
+// lockFile fails on this platform. Without advisory file locks, processes sharing a state
+// file would overwrite each other's changes; use a Store instead.
+func lockFile(path string, _ bool) (func(), error) {
+	return nil, fmt.Errorf("locking %s: %w", path, ErrFileLockUnsupported)
+}
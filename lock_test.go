@@ -0,0 +1,81 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+
+package subscribe
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFile(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "locked.json")
+
+	unlock, err := lockFile(stateFile, true)
+	require.NoError(t, err)
+	assert.FileExists(t, stateFile+lockSuffix)
+
+	acquired := make(chan struct{})
+
+	go func() {
+		unlockShared, err := lockFile(stateFile, false)
+		if err == nil {
+			unlockShared()
+		}
+
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("a shared lock must wait for the exclusive lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("the shared lock must be acquired after unlocking")
+	}
+
+	_, err = lockFile(filepath.Join(stateFile, "not", "a", "dir"), false)
+	require.Error(t, err)
+}
+
+func TestStateFileContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	sub.CreateSub("user", "pushover", false, false)
+
+	// Another process holding the lock blocks reads and writes.
+	unlock, err := lockFile(path, true)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, sub.StateFileLoadContext(ctx), context.DeadlineExceeded)
+	assert.Len(t, sub.Subscribers, 1, "an abandoned load must not change the database")
+
+	ctx, cancel = context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, sub.StateFileSaveContext(ctx), context.DeadlineExceeded)
+
+	unlock()
+
+	require.NoError(t, sub.StateFileLoadContext(t.Context()))
+	assert.Empty(t, sub.Subscribers, "the abandoned save must not write once the lock is released")
+}
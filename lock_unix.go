@@ -0,0 +1,47 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package subscribe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockSuffix is appended to the state file path to name its lock file.
+const lockSuffix = ".lock"
+
+// lockFile takes an advisory flock on a lock file next to path. Blocks until the lock is acquired.
+// Shared locks are used for reading, exclusive locks for writing. Returns a function to unlock.
+func lockFile(path string, exclusive bool) (func(), error) {
+	const lockFileMode = 0o600
+
+	// #nosec G304 -- lock path is derived from the user-configured state file.
+	file, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDWR, lockFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), how) // #nosec G115 -- file descriptors fit in an int.
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+
+	return func() {
+		// Closing the file releases the lock.
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package subscribe
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockSuffix is appended to the state file path to name its lock file.
+const lockSuffix = ".lock"
+
+// lockFile takes a LockFileEx lock on a lock file next to path. Blocks until the lock is acquired.
+// Shared locks are used for reading, exclusive locks for writing. Returns a function to unlock.
+func lockFile(path string, exclusive bool) (func(), error) {
+	const lockFileMode = 0o600
+
+	// #nosec G304 -- lock path is derived from the user-configured state file.
+	file, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDWR, lockFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	handle := windows.Handle(file.Fd())
+	overlapped := new(windows.Overlapped)
+
+	// Lock the whole (empty) file; the range only has to match UnlockFileEx.
+	err = windows.LockFileEx(handle, flags, 0, math.MaxUint32, math.MaxUint32, overlapped)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+
+	return func() {
+		_ = windows.UnlockFileEx(handle, 0, math.MaxUint32, math.MaxUint32, overlapped)
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,200 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+/*************************
+ *    Logging Methods    *
+ *************************/
+
+// changeMessages are the log messages for journaled operations. Others use "Changed database".
+var changeMessages = map[string]string{
+	OpSubscriber:      "Saved subscriber",
+	OpUnsubscribe:     "Removed subscriber",
+	OpNew:             "Added event",
+	OpPause:           "Paused event",
+	OpRemove:          "Removed event",
+	OpRename:          "Renamed event",
+	OpRuleSet:         "Set rule",
+	OpRuleDel:         "Deleted rule",
+	OpNamespace:       "Created namespace",
+	OpNamespaceRemove: "Removed namespace",
+	OpGroup:           "Created group",
+	OpGroupRemove:     "Removed group",
+}
+
+// SetLogger sends structured log records to a logger. Loads and relocations are logged at
+// info level and saves at debug level, with failures at error level. Removing subscribers,
+// groups, namespaces and events is logged at info level, and every other change at debug
+// level. A nil logger, the default, discards the records. The logger is shared by every namespace.
+// Changes are handed to the logger from another goroutine, in order, so a slow handler does
+// not hold up the database; loads, saves and relocations wait for them to be logged.
+func (s *Subscribe) SetLogger(logger *slog.Logger) {
+	root := s.root()
+
+	root.hookMu.Lock()
+	root.log = logger
+	root.hookMu.Unlock()
+
+	root.attachHooks()
+}
+
+// logQueue holds log records until a goroutine hands them to the logger, so handlers never
+// run while the database is locked. The zero value is ready to use.
+type logQueue struct {
+	mu       sync.Mutex
+	records  []*queuedLog
+	draining bool
+	// idle is closed when the running drain finishes.
+	idle chan struct{}
+}
+
+// queuedLog is a record waiting for its logger.
+type queuedLog struct {
+	logger *slog.Logger
+	record slog.Record
+}
+
+// queueLog queues a record for the logger, if there is one. It is safe to call with database locks held.
+func (s *Subscribe) queueLog(level slog.Level, msg string, attrs ...slog.Attr) {
+	root := s.root()
+
+	root.hookMu.RLock()
+	logger := root.log
+	root.hookMu.RUnlock()
+
+	if logger == nil {
+		return
+	}
+
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	record.AddAttrs(attrs...)
+
+	queue := &root.logs
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queue.records = append(queue.records, &queuedLog{logger: logger, record: record})
+	if !queue.draining {
+		queue.draining = true
+		queue.idle = make(chan struct{})
+
+		go queue.drain(queue.idle)
+	}
+}
+
+// flushLogs waits for the records queued so far to be logged. Call without database locks held.
+func (s *Subscribe) flushLogs() {
+	queue := &s.root().logs
+
+	queue.mu.Lock()
+	idle, draining := queue.idle, queue.draining
+	queue.mu.Unlock()
+
+	if draining {
+		<-idle
+	}
+}
+
+// drain logs queued records, in order, until the queue is empty, then closes idle.
+func (q *logQueue) drain(idle chan struct{}) {
+	defer close(idle)
+
+	ctx := context.Background()
+
+	for {
+		q.mu.Lock()
+		records := q.records
+		q.records = nil
+
+		if len(records) == 0 {
+			q.draining = false
+			q.mu.Unlock()
+
+			return
+		}
+
+		q.mu.Unlock()
+
+		for _, queued := range records {
+			if queued.logger.Enabled(ctx, queued.record.Level) {
+				_ = queued.logger.Handler().Handle(ctx, queued.record)
+			}
+		}
+	}
+}
+
+// logChange logs a change to the database.
+func (s *Subscribe) logChange(c *Change) {
+	level := slog.LevelDebug
+	if c.Op == OpUnsubscribe || c.Op == OpNamespaceRemove || c.Op == OpGroupRemove ||
+		(c.Op == OpRemove && c.Sub == nil && c.Group == "") {
+		level = slog.LevelInfo
+	}
+
+	msg, ok := changeMessages[c.Op]
+	if !ok {
+		msg = "Changed database"
+	}
+
+	attrs := []slog.Attr{slog.String("op", c.Op)}
+
+	for _, attr := range []struct{ key, val string }{
+		{"namespace", c.Namespace},
+		{"group", c.Group},
+		{"event", c.Event},
+		{"to", c.To},
+		{"kind", c.Kind},
+		{"rule", c.Rule},
+	} {
+		if attr.val != "" {
+			attrs = append(attrs, slog.String(attr.key, attr.val))
+		}
+	}
+
+	if c.Sub != nil {
+		attrs = append(attrs, slog.String("subscriber", c.Sub.String()))
+	}
+
+	if !c.Pause.IsZero() {
+		attrs = append(attrs, slog.Time("pause", c.Pause))
+	}
+
+	s.queueLog(level, msg, attrs...)
+}
+
+func (s *Subscribe) logLoad(elapsed time.Duration, err error) {
+	s.mu.RLock()
+	path, subscribers := s.stateFile, len(s.Subscribers)
+	s.mu.RUnlock()
+
+	if err != nil {
+		s.queueLog(slog.LevelError, "Loading state file failed", slog.String("path", path), slog.Any("error", err))
+	} else {
+		s.queueLog(slog.LevelInfo, "Loaded state file",
+			slog.String("path", path), slog.Int("subscribers", subscribers), slog.Duration("elapsed", elapsed))
+	}
+
+	s.flushLogs()
+}
+
+func (s *Subscribe) logSave(err error) {
+	path := slog.String("path", s.stateFilePath())
+
+	switch {
+	case errors.Is(err, ErrStateFileChanged):
+		s.queueLog(slog.LevelWarn, "State file changed by someone else; not saved", path, slog.Any("error", err))
+	case err != nil:
+		s.queueLog(slog.LevelError, "Saving state file failed", path, slog.Any("error", err))
+	default:
+		s.queueLog(slog.LevelDebug, "Saved state file", path)
+	}
+
+	s.flushLogs()
+}
@@ -0,0 +1,109 @@
+package subscribe
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLogger(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	sub.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	user := sub.CreateSub("user", "pushover", false, false)
+	require.NoError(t, sub.Events.New("motion", nil))
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, user.Events.Pause("motion", time.Hour))
+	sub.EventRemove("motion")
+	require.NoError(t, sub.StateFileSave())
+	require.NoError(t, sub.StateFileLoad())
+	require.NoError(t, sub.StateFileRelocate(filepath.Join(t.TempDir(), "moved.json")))
+
+	out := buf.String()
+	assertions.Contains(out, `"level":"DEBUG","msg":"Saved subscriber","op":"subscriber","subscriber":"pushover:user"`)
+	assertions.Contains(out, `"msg":"Paused event","op":"pause","event":"motion","subscriber":"pushover:user"`)
+	assertions.Contains(out, `"level":"INFO","msg":"Removed event","op":"remove","event":"motion"`)
+	assertions.Contains(out, `"msg":"Saved state file","path":"`+path)
+	assertions.Contains(out, `"level":"INFO","msg":"Loaded state file","path":"`+path+`","subscribers":1`)
+	assertions.Contains(out, `"level":"INFO","msg":"Relocated state file","from":"`+path)
+
+	buf.Reset()
+	sub.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	require.NoError(t, user.Events.New("door", nil))
+	sub.flushLogs()
+	assertions.Empty(buf.String(), "debug records are not written at info level")
+}
+
+// lookupHandler looks up subscribers for every record it handles, which deadlocks if it
+// runs while the database is locked.
+type lookupHandler struct {
+	slog.Handler
+	sub     *Subscribe
+	handled atomic.Int32
+}
+
+func (h *lookupHandler) Handle(context.Context, slog.Record) error {
+	h.sub.GetSubscribers("motion")
+	h.handled.Add(1)
+
+	return nil
+}
+
+func TestLoggerUnlocked(t *testing.T) {
+	t.Parallel()
+
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	handler := &lookupHandler{
+		Handler: slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		sub:     sub,
+	}
+	sub.SetLogger(slog.New(handler))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		sub.CreateSub("user", "pushover", false, false)
+		sub.EventRemove("motion")
+		sub.flushLogs()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a handler that uses the database must not deadlock")
+	}
+
+	assert.Positive(t, handler.handled.Load())
+}
+
+func TestLoggerDefault(t *testing.T) {
+	t.Parallel()
+
+	sub, err := GetDB(filepath.Join(t.TempDir(), "subscribers.json"))
+	require.NoError(t, err)
+
+	assert.NotPanics(t, func() {
+		sub.CreateSub("user", "pushover", false, false)
+		sub.EventRemove("motion")
+		_ = sub.StateFileSave()
+	})
+}
@@ -0,0 +1,309 @@
+package subscribe
+
+import (
+	"maps"
+	"reflect"
+	"time"
+)
+
+/***********************
+ *    Merge Methods    *
+ ***********************/
+
+// MergePolicy decides which side wins when both databases have different values
+// for the same pause time, rule or subscriber flag.
+type MergePolicy int
+
+const (
+	// MergeNewestPause takes the pause time and conflicting rules from the side paused latest.
+	// Ties, and subscriber flags (Admin, Ignored, Meta), keep the local values.
+	MergeNewestPause MergePolicy = iota
+	// MergeKeepLocal keeps local values for every conflict.
+	MergeKeepLocal
+	// MergeKeepRemote takes the other database's values for every conflict.
+	MergeKeepRemote
+)
+
+// MergeSummary describes what Merge changed in the local database.
+type MergeSummary struct {
+	// EventsAdded lists global events that only existed in the other database.
+	EventsAdded []string
+	// EventsUpdated lists global events that existed on both sides and had their rules changed.
+	EventsUpdated []string
+	// SubscribersAdded is the number of subscribers copied from the other database.
+	SubscribersAdded int
+	// SubscribersUpdated is the number of existing subscribers that changed in any way.
+	SubscribersUpdated int
+	// SubscriptionsAdded is the number of event subscriptions added to existing subscribers.
+	SubscriptionsAdded int
+	// SubscriptionsUpdated is the number of existing subscriptions that had rules changed.
+	SubscriptionsUpdated int
+	// Conflicts is the number of values that differed between the databases and were
+	// resolved by the policy, including ones the policy resolved to the local value.
+	Conflicts int
+	// Skipped lists the parts of the other database that were not empty and were left out,
+	// from: groups, definitions, aliases, severities, namespaces, digests, escalations and occurrences.
+	Skipped []string
+}
+
+// Merge combines another database into this one. Global events are combined, and the
+// other database's subscribers are matched to local subscribers the same way CreateSub
+// (by contact) and CreateSubWithID (by ID, when not 0) match them. Unmatched subscribers
+// are copied in. Matched subscribers get the union of both sides' subscriptions and rules,
+// with conflicting values resolved by policy. The other database is not modified.
+// Only events and subscribers are merged; the rest of the other database is left out,
+// and listed in the summary's Skipped. Merge namespaces by calling Merge on each of them.
+func (s *Subscribe) Merge(other *Subscribe, policy MergePolicy) *MergeSummary {
+	remote := other.snapshot()
+	summary := &MergeSummary{EventsAdded: []string{}, EventsUpdated: []string{}, Skipped: skippedParts(remote)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Events == nil {
+		s.Events = &Events{Map: make(map[string]*Rules)}
+	}
+
+	for _, event := range remote.Events.Names() {
+		added, updated, conflicts := s.Events.merge(event, remote.Events.Map[event], policy)
+		summary.Conflicts += conflicts
+
+		if added {
+			summary.EventsAdded = append(summary.EventsAdded, event)
+		} else if updated {
+			summary.EventsUpdated = append(summary.EventsUpdated, event)
+		}
+	}
+
+	for _, sub := range remote.Subscribers {
+		if sub != nil {
+			s.mergeSubscriberLocked(sub, policy, summary)
+		}
+	}
+
+	return summary
+}
+
+// skippedParts returns the names of the parts of a database Merge does not combine, and that are not empty.
+func skippedParts(db *Subscribe) []string {
+	skipped := []string{}
+
+	for _, part := range []struct {
+		name  string
+		count int
+	}{
+		{"groups", len(db.Groups)},
+		{"definitions", len(db.Definitions)},
+		{"aliases", len(db.Aliases)},
+		{"severities", len(db.Severities)},
+		{"namespaces", len(db.Namespaces)},
+		{"digests", len(db.Digests)},
+		{"escalations", len(db.Escalations)},
+		{"occurrences", len(db.Occurrences)},
+	} {
+		if part.count > 0 {
+			skipped = append(skipped, part.name)
+		}
+	}
+
+	return skipped
+}
+
+// mergeSubscriberLocked merges a single remote subscriber into the local list. Call with mu held.
+func (s *Subscribe) mergeSubscriberLocked(remote *Subscriber, policy MergePolicy, summary *MergeSummary) {
+	local := s.findSubscriberLocked(remote.ID, remote.Contact, remote.API)
+	if local == nil {
+		// remote is already a private copy made by snapshot().
+		s.Subscribers = append(s.Subscribers, remote)
+		s.emitSubscriber(remote)
+		s.attachSubscriberHook(remote)
+		remote.Events.emitAll()
+
+		summary.SubscribersAdded++
+
+		return
+	}
+
+	updated := false
+
+	if local.Admin != remote.Admin || local.Ignored != remote.Ignored {
+		summary.Conflicts++
+
+		if policy == MergeKeepRemote {
+			local.Admin, local.Ignored = remote.Admin, remote.Ignored
+			s.emitSubscriber(local)
+			updated = true
+		}
+	}
+
+	if mergeMeta(local, remote, policy, summary) {
+		updated = true
+	}
+
+	for _, event := range remote.Events.Names() {
+		added, changed, conflicts := local.Events.merge(event, remote.Events.Map[event], policy)
+		summary.Conflicts += conflicts
+
+		switch {
+		case added:
+			summary.SubscriptionsAdded++
+		case changed:
+			summary.SubscriptionsUpdated++
+		default:
+			continue
+		}
+
+		updated = true
+	}
+
+	if updated {
+		summary.SubscribersUpdated++
+	}
+}
+
+// findSubscriberLocked matches a subscriber like CreateSubWithID when id is not 0, otherwise like CreateSub.
+func (s *Subscribe) findSubscriberLocked(id int64, contact, api string) *Subscriber {
+	for _, sub := range s.Subscribers {
+		if sub != nil && sub.matches(&SubscriberRef{ID: id, Contact: contact, API: api}) {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// mergeMeta copies missing (and, with MergeKeepRemote, conflicting) Meta keys. Returns true if any changed.
+func mergeMeta(local, remote *Subscriber, policy MergePolicy, summary *MergeSummary) bool {
+	changed := false
+
+	for key, val := range remote.Meta {
+		current, exists := local.Meta[key]
+		if exists && reflect.DeepEqual(current, val) {
+			continue
+		}
+
+		if exists {
+			summary.Conflicts++
+
+			if policy != MergeKeepRemote {
+				continue
+			}
+		}
+
+		if local.Meta == nil {
+			local.Meta = make(map[string]any)
+		}
+
+		local.Meta[key] = val
+		changed = true
+	}
+
+	return changed
+}
+
+// emitAll records every event as new. Used when an Events map is added to the database whole.
+func (e *Events) emitAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for event, rules := range e.Map {
+		e.emitLocked(&Change{Op: OpNew, Event: event, Rules: rules})
+	}
+}
+
+// merge combines remote rules into an event. Returns whether the event was added or
+// changed, and the number of conflicting values.
+func (e *Events) merge(event string, remote *Rules, policy MergePolicy) (bool, bool, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	event = e.keyLocked(event)
+
+	local, exists := e.Map[event]
+	if !exists {
+		e.Map[event] = cloneRules(remote)
+		e.emitLocked(&Change{Op: OpNew, Event: event, Rules: e.Map[event]})
+
+		return true, false, 0
+	}
+
+	merged, conflicts := mergeRules(cloneRules(local), cloneRules(remote), policy)
+	if rulesEqual(merged, cloneRules(local)) {
+		return false, false, conflicts
+	}
+
+	e.Map[event] = merged
+	e.emitLocked(&Change{Op: OpNew, Event: event, Rules: merged})
+
+	return false, true, conflicts
+}
+
+// mergeRules returns the union of two rule sets. The policy picks a winning side, whose pause
+// time and conflicting rule values are kept. Also returns the number of conflicting values.
+func mergeRules(local, remote *Rules, policy MergePolicy) (*Rules, int) {
+	remoteWins := policy == MergeKeepRemote ||
+		(policy == MergeNewestPause && remote.Pause.After(local.Pause))
+
+	conflicts := countConflicts(local.D, remote.D, equal[time.Duration]) +
+		countConflicts(local.I, remote.I, equal[int]) +
+		countConflicts(local.S, remote.S, equal[string]) +
+		countConflicts(local.T, remote.T, time.Time.Equal)
+	if !local.Pause.Equal(remote.Pause) {
+		conflicts++
+	}
+
+	winner, loser := local, remote
+	if remoteWins {
+		winner, loser = remote, local
+	}
+
+	merged := cloneRules(loser)
+	merged.Pause = winner.Pause
+
+	maps.Copy(merged.D, winner.D)
+	maps.Copy(merged.I, winner.I)
+	maps.Copy(merged.S, winner.S)
+	maps.Copy(merged.T, winner.T)
+
+	return merged, conflicts
+}
+
+// rulesEqual returns true if both rule sets have the same pause time and rules.
+func rulesEqual(left, right *Rules) bool {
+	return left.Pause.Equal(right.Pause) &&
+		mapsEqual(left.D, right.D, equal[time.Duration]) &&
+		mapsEqual(left.I, right.I, equal[int]) &&
+		mapsEqual(left.S, right.S, equal[string]) &&
+		mapsEqual(left.T, right.T, time.Time.Equal)
+}
+
+func mapsEqual[V any](left, right map[string]V, equal func(V, V) bool) bool {
+	return len(left) == len(right) && countConflicts(left, right, equal) == 0 && keysMatch(left, right)
+}
+
+func keysMatch[V any](left, right map[string]V) bool {
+	for key := range left {
+		if _, ok := right[key]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// countConflicts returns the number of keys present in both maps with different values.
+func countConflicts[V any](left, right map[string]V, equal func(V, V) bool) int {
+	count := 0
+
+	for key, val := range left {
+		if other, ok := right[key]; ok && !equal(val, other) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func equal[V comparable](a, b V) bool {
+	return a == b
+}
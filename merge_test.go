@@ -0,0 +1,130 @@
+package subscribe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeFixture returns two databases with overlapping events and subscribers.
+func mergeFixture(t *testing.T) (*Subscribe, *Subscribe) {
+	t.Helper()
+
+	local := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	remote := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+
+	require.NoError(t, local.Events.New("shared", nil))
+	require.NoError(t, remote.Events.New("shared", nil))
+	require.NoError(t, remote.Events.New("remote-only", nil))
+	remote.Events.RuleSetS("shared", "color", "red")
+
+	mine := local.CreateSub("both", "api", true, false)
+	require.NoError(t, mine.Subscribe("evt"))
+	require.NoError(t, mine.Events.Pause("evt", time.Hour))
+	mine.Events.RuleSetI("evt", "count", 1)
+	mine.Events.RuleSetS("evt", "local", "yes")
+
+	theirs := remote.CreateSub("both", "api", false, true)
+	require.NoError(t, theirs.Subscribe("evt"))
+	require.NoError(t, theirs.Subscribe("extra"))
+	require.NoError(t, theirs.Events.Pause("evt", 2*time.Hour))
+	theirs.Events.RuleSetI("evt", "count", 2)
+	theirs.Events.RuleSetS("evt", "remote", "yes")
+
+	remote.CreateSubWithID(5, "new", "api", false, false)
+	local.CreateSubWithID(5, "renamed", "other-api", false, false)
+
+	return local, remote
+}
+
+func TestMergeNewestPause(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	local, remote := mergeFixture(t)
+
+	summary := local.Merge(remote, MergeNewestPause)
+	assertions.Equal([]string{"remote-only"}, summary.EventsAdded)
+	assertions.Equal([]string{"shared"}, summary.EventsUpdated)
+	assertions.Equal(1, summary.SubscribersAdded, "the ID must match on API too")
+	assertions.Equal(1, summary.SubscribersUpdated)
+	assertions.Equal(1, summary.SubscriptionsAdded)
+	assertions.Equal(1, summary.SubscriptionsUpdated)
+	// Admin/Ignored flags, the pause time and the count rule.
+	assertions.Equal(3, summary.Conflicts)
+
+	both, err := local.GetSubscriber("both", "api")
+	require.NoError(t, err)
+	assertions.True(both.Admin, "flags must stay local")
+	assertions.Equal([]string{"evt", "extra"}, both.Events.Names())
+
+	count, _ := both.Events.RuleGetI("evt", "count")
+	assertions.Equal(2, count, "the newest pause must win the conflict")
+
+	_, ok := both.Events.RuleGetS("evt", "local")
+	assertions.True(ok, "rules must be combined")
+	_, ok = both.Events.RuleGetS("evt", "remote")
+	assertions.True(ok, "rules must be combined")
+	assertions.WithinDuration(time.Now().Add(2*time.Hour), both.Events.PauseTime("evt"), time.Second)
+
+	_, err = local.GetSubscriberByID(5, "api")
+	require.NoError(t, err)
+
+	// Merging the same data again changes nothing.
+	summary = local.Merge(remote, MergeNewestPause)
+	assertions.Empty(summary.EventsAdded)
+	assertions.Empty(summary.EventsUpdated)
+	assertions.Zero(summary.SubscribersAdded)
+	assertions.Zero(summary.SubscribersUpdated)
+}
+
+func TestMergeKeepLocal(t *testing.T) {
+	t.Parallel()
+
+	local, remote := mergeFixture(t)
+	local.Merge(remote, MergeKeepLocal)
+
+	both, err := local.GetSubscriber("both", "api")
+	require.NoError(t, err)
+
+	count, _ := both.Events.RuleGetI("evt", "count")
+	assert.Equal(t, 1, count)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), both.Events.PauseTime("evt"), time.Second)
+	assert.True(t, both.Admin)
+}
+
+func TestMergeKeepRemote(t *testing.T) {
+	t.Parallel()
+
+	local, remote := mergeFixture(t)
+	remote.Subscribers[0].Meta = map[string]any{"name": "remote", "extra": 1}
+	local.Subscribers[0].Meta = map[string]any{"name": "local"}
+	local.Merge(remote, MergeKeepRemote)
+
+	both, err := local.GetSubscriber("both", "api")
+	require.NoError(t, err)
+	assert.False(t, both.Admin)
+	assert.True(t, both.Ignored)
+	assert.Equal(t, map[string]any{"name": "remote", "extra": 1}, both.Meta)
+
+	// The remote database is never modified, and the copy is independent.
+	require.NoError(t, local.Subscribers[2].Subscribe("local-change"))
+	assert.False(t, remote.Subscribers[1].Events.Exists("local-change"))
+}
+
+func TestMergeSkipped(t *testing.T) {
+	t.Parallel()
+
+	local, remote := mergeFixture(t)
+	assert.Empty(t, local.Merge(remote, MergeNewestPause).Skipped)
+
+	remote.SetSeverities("info", "critical")
+	require.NoError(t, remote.DefineEvent(&EventDefinition{Name: "shared"}))
+	remote.Namespace("tenant")
+
+	summary := local.Merge(remote, MergeNewestPause)
+	assert.Equal(t, []string{"definitions", "severities", "namespaces"}, summary.Skipped)
+	assert.Empty(t, local.EventCatalog(), "definitions must not be merged")
+}
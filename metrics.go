@@ -0,0 +1,288 @@
+package subscribe
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*************************
+ *    Metrics Methods    *
+ *************************/
+
+// metrics counts calls for WriteMetrics.
+type metrics struct {
+	calls        atomic.Uint64
+	results      atomic.Uint64
+	saves        atomic.Uint64
+	saveFailures atomic.Uint64
+	loads        atomic.Uint64
+	loadFailures atomic.Uint64
+	loadNanos    atomic.Int64
+}
+
+// MetricsHandler returns an http.Handler that serves WriteMetrics, and turns on metrics.
+func (s *Subscribe) MetricsHandler() http.Handler {
+	s.MetricsEnable()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = s.WriteMetrics(w)
+	})
+}
+
+// MetricsEnable starts counting GetSubscribers calls, saves and loads for WriteMetrics.
+// Counting is off until this, or MetricsHandler, is called. Counters are kept in the root database.
+func (s *Subscribe) MetricsEnable() {
+	root := s.root()
+
+	root.hookMu.Lock()
+	defer root.hookMu.Unlock()
+
+	if root.metrics == nil {
+		root.metrics = &metrics{}
+	}
+}
+
+// WriteMetrics writes gauges describing the database, and the counters started by
+// MetricsEnable, in the Prometheus text exposition format. Every namespace is included,
+// with the root database as namespace="".
+func (s *Subscribe) WriteMetrics(w io.Writer) error {
+	root := s.root()
+	buf := bufio.NewWriter(w)
+	gauges := collectGauges(root)
+
+	for _, gauge := range gauges {
+		gauge.write(buf)
+	}
+
+	root.hookMu.RLock()
+	counts := root.metrics
+	root.hookMu.RUnlock()
+
+	if counts != nil {
+		counts.write(buf)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+
+	return nil
+}
+
+// metric is a metric family with its samples.
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	samples []*sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func (m *metric) add(value float64, labels ...string) {
+	m.samples = append(m.samples, &sample{labels: formatLabels(labels), value: value})
+}
+
+func (m *metric) write(buf *bufio.Writer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+
+	for _, sample := range m.samples {
+		fmt.Fprintf(buf, "%s%s %v\n", m.name, sample.labels, sample.value)
+	}
+}
+
+// gaugeCounts are the gauges of a single database or namespace.
+type gaugeCounts struct {
+	byAPI, byEvent                 map[string]int
+	admins, ignored, paused, total int
+}
+
+// collectGauges counts subscribers and subscriptions in a database and its namespaces.
+func collectGauges(root *Subscribe) []*metric {
+	subscribers := &metric{name: "subscribe_subscribers", help: "Subscribers by API.", kind: "gauge"}
+	admins := &metric{name: "subscribe_admins", help: "Admin subscribers.", kind: "gauge"}
+	ignored := &metric{name: "subscribe_ignored", help: "Ignored subscribers.", kind: "gauge"}
+	events := &metric{name: "subscribe_events", help: "Events in the global Events list.", kind: "gauge"}
+	subscriptions := &metric{name: "subscribe_subscriptions", help: "Subscriptions by event.", kind: "gauge"}
+	paused := &metric{name: "subscribe_paused_subscriptions", help: "Subscriptions paused now.", kind: "gauge"}
+
+	root.mu.RLock()
+	databases := make(map[string]*Subscribe, len(root.Namespaces)+1)
+
+	for name, ns := range root.Namespaces {
+		databases[name] = ns
+	}
+
+	root.mu.RUnlock()
+
+	databases[""] = root
+	now := time.Now()
+
+	for _, name := range sortedKeys(databases) {
+		counts := databases[name].countGauges(now)
+
+		for _, api := range sortedKeys(counts.byAPI) {
+			subscribers.add(float64(counts.byAPI[api]), "namespace", name, "api", api)
+		}
+
+		for _, event := range sortedKeys(counts.byEvent) {
+			subscriptions.add(float64(counts.byEvent[event]), "namespace", name, "event", event)
+		}
+
+		admins.add(float64(counts.admins), "namespace", name)
+		ignored.add(float64(counts.ignored), "namespace", name)
+		paused.add(float64(counts.paused), "namespace", name)
+		events.add(float64(counts.total), "namespace", name)
+	}
+
+	return []*metric{subscribers, admins, ignored, events, subscriptions, paused}
+}
+
+// countGauges counts a database's subscribers, subscriptions and events, without its namespaces.
+func (s *Subscribe) countGauges(now time.Time) *gaugeCounts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := &gaugeCounts{byAPI: map[string]int{}, byEvent: map[string]int{}}
+
+	for _, sub := range s.Subscribers {
+		if sub == nil {
+			continue
+		}
+
+		counts.byAPI[sub.API]++
+
+		if sub.Admin {
+			counts.admins++
+		}
+
+		if sub.Ignored {
+			counts.ignored++
+		}
+
+		if sub.Events == nil {
+			continue
+		}
+
+		sub.Events.mu.RLock()
+
+		for event, rules := range sub.Events.Map {
+			counts.byEvent[event]++
+
+			if rules != nil && rules.Pause.After(now) {
+				counts.paused++
+			}
+		}
+
+		sub.Events.mu.RUnlock()
+	}
+
+	if s.Events != nil {
+		s.Events.mu.RLock()
+		counts.total = len(s.Events.Map)
+		s.Events.mu.RUnlock()
+	}
+
+	return counts
+}
+
+func (m *metrics) write(buf *bufio.Writer) {
+	counters := []*metric{
+		{name: "subscribe_get_subscribers_total", help: "GetSubscribers calls, including variants.", kind: "counter"},
+		{name: "subscribe_get_subscribers_results_total", help: "Subscribers returned by GetSubscribers.", kind: "counter"},
+		{name: "subscribe_state_saves_total", help: "StateFileSave calls.", kind: "counter"},
+		{name: "subscribe_state_save_failures_total", help: "StateFileSave calls that failed.", kind: "counter"},
+		{name: "subscribe_state_load_failures_total", help: "StateFileLoad calls that failed.", kind: "counter"},
+		{name: "subscribe_state_load_duration_seconds", help: "StateFileLoad durations.", kind: "summary"},
+	}
+
+	counters[0].add(float64(m.calls.Load()))
+	counters[1].add(float64(m.results.Load()))
+	counters[2].add(float64(m.saves.Load()))
+	counters[3].add(float64(m.saveFailures.Load()))
+	counters[4].add(float64(m.loadFailures.Load()))
+
+	for _, counter := range counters[:5] {
+		counter.write(buf)
+	}
+
+	load := counters[5]
+	load.write(buf)
+	fmt.Fprintf(buf, "%s_sum %v\n%s_count %d\n", load.name,
+		time.Duration(m.loadNanos.Load()).Seconds(), load.name, m.loads.Load())
+}
+
+// countCall counts a GetSubscribers call.
+func (s *Subscribe) countCall(results int) {
+	if counts := s.counters(); counts != nil {
+		counts.calls.Add(1)
+		counts.results.Add(uint64(results)) // #nosec G115 -- results is a slice length.
+	}
+}
+
+func (s *Subscribe) countSave(err error) {
+	if counts := s.counters(); counts != nil {
+		counts.saves.Add(1)
+
+		if err != nil {
+			counts.saveFailures.Add(1)
+		}
+	}
+}
+
+func (s *Subscribe) countLoad(elapsed time.Duration, err error) {
+	if counts := s.counters(); counts != nil {
+		counts.loads.Add(1)
+		counts.loadNanos.Add(int64(elapsed))
+
+		if err != nil {
+			counts.loadFailures.Add(1)
+		}
+	}
+}
+
+func (s *Subscribe) counters() *metrics {
+	root := s.root()
+
+	root.hookMu.RLock()
+	defer root.hookMu.RUnlock()
+
+	return root.metrics
+}
+
+// formatLabels renders label name/value pairs like {name="value"}.
+func formatLabels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labels := make([]string, 0, len(pairs)/2)
+
+	for idx := 0; idx+1 < len(pairs); idx += 2 {
+		labels = append(labels, pairs[idx]+`="`+escaper.Replace(pairs[idx+1])+`"`)
+	}
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,81 @@
+package subscribe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	sub, err := GetDB(filepath.Join(t.TempDir(), "subscribers.json"))
+	require.NoError(t, err)
+
+	handler := sub.MetricsHandler()
+
+	require.NoError(t, sub.Events.New("motion", nil))
+	admin := sub.CreateSub("admin", "pushover", true, false)
+	user := sub.CreateSub("user", "pushover", false, false)
+	sub.CreateSub("quiet", "email", false, true)
+	sub.Namespace(`te"nant`).CreateSub("other", "slack", false, false)
+
+	require.NoError(t, admin.Subscribe("motion"))
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, user.Events.Pause("motion", time.Hour))
+
+	sub.GetSubscribers("motion")
+	sub.GetSubscribers("motion")
+	require.NoError(t, sub.StateFileSave())
+	require.NoError(t, sub.StateFileLoad())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE subscribe_subscribers gauge",
+		`subscribe_subscribers{namespace="",api="email"} 1`,
+		`subscribe_subscribers{namespace="",api="pushover"} 2`,
+		`subscribe_subscribers{namespace="te\"nant",api="slack"} 1`,
+		`subscribe_admins{namespace=""} 1`,
+		`subscribe_ignored{namespace=""} 1`,
+		`subscribe_events{namespace=""} 1`,
+		`subscribe_subscriptions{namespace="",event="motion"} 2`,
+		`subscribe_paused_subscriptions{namespace=""} 1`,
+		"# TYPE subscribe_get_subscribers_total counter",
+		"subscribe_get_subscribers_total 2",
+		"subscribe_get_subscribers_results_total 2",
+		"subscribe_state_saves_total 1",
+		"subscribe_state_save_failures_total 0",
+		"subscribe_state_load_failures_total 0",
+		"# TYPE subscribe_state_load_duration_seconds summary",
+		"subscribe_state_load_duration_seconds_count 1",
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+
+	assert.Equal(t, 1, strings.Count(body, "# HELP subscribe_state_saves_total"))
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	t.Parallel()
+
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	sub.GetSubscribers("motion")
+
+	var buf strings.Builder
+
+	require.NoError(t, sub.WriteMetrics(&buf))
+	assert.Contains(t, buf.String(), "subscribe_events")
+	assert.NotContains(t, buf.String(), "subscribe_get_subscribers_total", "counters must be off by default")
+}
@@ -0,0 +1,133 @@
+package subscribe
+
+import (
+	"sort"
+)
+
+/***************************
+ *    Namespace Methods    *
+ ***************************/
+
+// Namespace returns the named tenant partition of the database, creating it if needed.
+// A namespace has its own EnableAPIs, Events and Subscribers, and every method on the
+// returned Subscribe (GetSubscriber, GetSubscribers, GetAdmins, CreateSub, etc.) only
+// sees that namespace. Namespaces are saved in the parent's state file, and the state
+// file methods on a namespace act on the whole database. The empty name is the root
+// database. Namespaces do not nest: calling Namespace on a namespace is the same as calling
+// it on the root database, so it returns a sibling namespace, or the root for the empty name.
+func (s *Subscribe) Namespace(name string) *Subscribe {
+	if s.parent != nil {
+		return s.parent.Namespace(name)
+	}
+
+	if name == "" {
+		return s
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ns, ok := s.Namespaces[name]; ok {
+		return ns
+	}
+
+	if s.Namespaces == nil {
+		s.Namespaces = make(map[string]*Subscribe)
+	}
+
+	ns := &Subscribe{
+		EnableAPIs:  make([]string, 0),
+		Events:      &Events{Map: make(map[string]*Rules)},
+		Subscribers: make([]*Subscriber, 0),
+		parent:      s,
+		namespace:   name,
+	}
+	s.Namespaces[name] = ns
+	ns.attachHooks()
+	s.emit(&Change{Op: OpNamespace, Namespace: name})
+
+	return ns
+}
+
+// NamespaceNames returns the names of all namespaces, sorted.
+func (s *Subscribe) NamespaceNames() []string {
+	root := s.root()
+
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	names := make([]string, 0, len(root.Namespaces))
+
+	for name := range root.Namespaces {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// NamespaceRemove deletes a namespace and everything in it. Changes made later through
+// a handle to the removed namespace are not persisted.
+func (s *Subscribe) NamespaceRemove(name string) {
+	root := s.root()
+
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	ns, ok := root.Namespaces[name]
+	if !ok {
+		return
+	}
+
+	ns.detached.Store(true)
+	delete(root.Namespaces, name)
+	root.emit(&Change{Op: OpNamespaceRemove, Namespace: name})
+}
+
+// root returns the database a namespace belongs to, or s if it is not a namespace.
+func (s *Subscribe) root() *Subscribe {
+	if s.parent != nil {
+		return s.parent
+	}
+
+	return s
+}
+
+// adoptNamespacesLocked replaces the namespaces with freshly loaded ones. Namespaces that
+// already exist are updated in place, so callers holding them keep working. Namespaces missing
+// from loaded are detached like NamespaceRemove, so changes made through them are not persisted.
+// Call with mu held.
+func (s *Subscribe) adoptNamespacesLocked(loaded map[string]*Subscribe) {
+	current := s.Namespaces
+	s.Namespaces = make(map[string]*Subscribe, len(loaded))
+
+	for name, ns := range current {
+		if _, ok := loaded[name]; !ok {
+			ns.detached.Store(true)
+		}
+	}
+
+	for name, ns := range loaded {
+		if existing, ok := current[name]; ok {
+			existing.mu.Lock()
+			existing.EnableAPIs = ns.EnableAPIs
+			existing.Events = ns.Events
+			existing.Aliases = ns.Aliases
+			existing.Definitions = ns.Definitions
+			existing.Severities = ns.Severities
+			existing.adoptRecordsLocked(ns)
+			existing.Subscribers = ns.Subscribers
+			existing.setGroupsLocked(ns.Groups)
+			existing.mu.Unlock()
+
+			s.Namespaces[name] = existing
+
+			continue
+		}
+
+		ns.parent, ns.namespace = s, name
+		ns.setGroupsLocked(ns.Groups)
+		s.Namespaces[name] = ns
+	}
+}
@@ -0,0 +1,108 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceIsolation(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub, err := GetDB("")
+	require.NoError(t, err)
+
+	acme := sub.Namespace("acme")
+	globex := sub.Namespace("globex")
+	assertions.Same(acme, sub.Namespace("acme"))
+	assertions.Same(sub, sub.Namespace(""))
+	assertions.Same(globex, acme.Namespace("globex"), "namespaces do not nest")
+
+	require.NoError(t, acme.CreateSub("same", "api", true, false).Subscribe("evt"))
+	require.NoError(t, globex.CreateSub("same", "api", false, false).Subscribe("evt"))
+	globex.EnableAPIs = []string{"other"}
+
+	assertions.Len(acme.GetSubscribers("evt"), 1)
+	assertions.Empty(globex.GetSubscribers("evt"), "EnableAPIs must be per namespace")
+	assertions.Empty(sub.GetSubscribers("evt"), "the root must not see namespaced subscribers")
+	assertions.Len(acme.GetAdmins(), 1)
+	assertions.Empty(globex.GetAdmins())
+
+	_, err = sub.GetSubscriber("same", "api")
+	require.ErrorIs(t, err, ErrSubscriberNotFound)
+
+	assertions.Equal([]string{"acme", "globex"}, globex.NamespaceNames())
+	sub.NamespaceRemove("globex")
+	sub.NamespaceRemove("missing")
+	assertions.Equal([]string{"acme"}, sub.NamespaceNames())
+}
+
+func TestNamespacePersistence(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	stateFile := filepath.Join(t.TempDir(), "tenants.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+
+	acme := sub.Namespace("acme")
+	acme.EnableAPIs = []string{"smtp"}
+	require.NoError(t, acme.CreateSub("user", "smtp", false, false).Subscribe("evt"))
+	require.NoError(t, acme.StateFileSave(), "saving a namespace saves the whole database")
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err)
+	assertions.Empty(loaded.Subscribers)
+	assertions.Equal([]string{"smtp"}, loaded.Namespace("acme").EnableAPIs)
+	assertions.Len(loaded.Namespace("acme").GetSubscribers("evt"), 1)
+
+	// Reloading keeps namespace handles working.
+	require.NoError(t, acme.StateFileLoad())
+	assertions.Same(acme, sub.Namespace("acme"))
+	assertions.Len(acme.GetSubscribers("evt"), 1)
+
+	// Journaled namespace changes are replayed into the right namespace.
+	require.NoError(t, sub.JournalEnable(0))
+	acme.CreateSub("journaled", "smtp", false, false)
+	sub.Namespace("empty")
+	require.NoError(t, acme.JournalDisable())
+
+	loaded, err = GetDB(stateFile)
+	require.NoError(t, err)
+	assertions.Equal([]string{"acme", "empty"}, loaded.NamespaceNames())
+	assertions.Empty(loaded.Subscribers)
+
+	_, err = loaded.Namespace("acme").GetSubscriber("journaled", "smtp")
+	require.NoError(t, err)
+}
+
+func TestNamespaceDetach(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	stateFile := filepath.Join(t.TempDir(), "tenants.json")
+
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+	require.NoError(t, sub.StateFileSave())
+
+	dropped := sub.Namespace("dropped")
+	removed := sub.Namespace("removed")
+
+	require.NoError(t, sub.StateFileLoad(), "the state file has neither namespace")
+	sub.NamespaceRemove("removed")
+	assertions.Empty(sub.NamespaceNames())
+
+	require.NoError(t, sub.JournalEnable(0))
+	require.NoError(t, dropped.CreateSub("user", "smtp", false, false).Subscribe("evt"))
+	require.NoError(t, removed.CreateSub("user", "smtp", false, false).Subscribe("evt"))
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(stateFile)
+	require.NoError(t, err)
+	assertions.Empty(loaded.NamespaceNames(), "changes to detached namespaces must not be persisted")
+}
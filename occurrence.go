@@ -0,0 +1,401 @@
+package subscribe
+
+import (
+	"crypto/rand"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+)
+
+/****************************
+ *    Occurrence Methods    *
+ ****************************/
+
+// RuleAckPause is the duration rule that pauses a subscription when the subscriber acknowledges
+// an occurrence of it. Set it with SetAckPause.
+const RuleAckPause = "ackPause"
+
+// DefaultOccurrenceLimit is the number of occurrence records kept when no limit is set.
+const DefaultOccurrenceLimit = 1000
+
+// Responses a subscriber can make to an occurrence.
+const (
+	ResponseAck    = "ack"
+	ResponseSnooze = "snooze"
+)
+
+// OccurrenceRecord is an occurrence returned by Dispatch or Escalate, with who was notified
+// and how they responded.
+type OccurrenceRecord struct {
+	// ID identifies the occurrence for Acknowledge and Snooze.
+	ID string `json:"id"`
+	// Occurrence is the occurrence, with aliases and default severity resolved.
+	Occurrence *Occurrence `json:"occurrence"`
+	// Notified is everyone notified of the occurrence.
+	Notified []*SubscriberRef `json:"notified,omitempty"`
+	// Responses are the acknowledgements and snoozes, oldest first.
+	Responses []*Response `json:"responses,omitempty"`
+}
+
+// Response is a subscriber acknowledging or snoozing an occurrence.
+type Response struct {
+	Subscriber SubscriberRef `json:"subscriber"`
+	// Action is ResponseAck or ResponseSnooze.
+	Action string    `json:"action"`
+	At     time.Time `json:"at"`
+	// Until is when a snooze ends.
+	Until time.Time `json:"until,omitzero"`
+	// Reminded is true once Reminders returned this snooze.
+	Reminded bool `json:"reminded,omitempty"`
+}
+
+// Reminder is a snooze that ended before the subscriber acknowledged the occurrence.
+type Reminder struct {
+	ID         string
+	Event      string
+	Subscriber *Subscriber
+}
+
+// SetAckPause makes acknowledging an occurrence pause the subscription for d,
+// or with d <= 0, turns that off.
+func (e *Events) SetAckPause(event string, d time.Duration) {
+	if d <= 0 {
+		e.RuleDelD(event, RuleAckPause)
+	} else {
+		e.RuleSetD(event, RuleAckPause, d)
+	}
+}
+
+// SetOccurrenceLimit sets how many occurrence records are kept. Recording more prunes the
+// oldest, except ones still being escalated. Use a limit <= 0 for DefaultOccurrenceLimit.
+// Each namespace has its own records and limit.
+func (s *Subscribe) SetOccurrenceLimit(limit int) {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	s.occurrenceLimit = limit
+	s.limitOccurrencesLocked()
+}
+
+// Dispatch works like GetOccurrenceSubscribers, and also records the occurrence and who
+// was notified of it, and adds it to the digests of digest subscriptions. Returns the ID
+// to pass to Acknowledge and Snooze. Occurrences nobody was notified of cannot be responded
+// to, so they are not recorded, and the ID is empty. See SetOccurrenceLimit.
+func (s *Subscribe) Dispatch(occurrence *Occurrence) (string, []*Subscriber, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs, err := s.occurrenceSubscribersLocked(occurrence, lookupDispatch)
+	if err != nil || len(subs) == 0 {
+		return "", subs, err
+	}
+
+	rec := &OccurrenceRecord{
+		ID:         rand.Text(),
+		Occurrence: s.resolveOccurrenceLocked(occurrence),
+		Notified:   make([]*SubscriberRef, 0, len(subs)),
+	}
+	if rec.Occurrence.Time.IsZero() {
+		rec.Occurrence.Time = time.Now()
+	}
+
+	for _, sub := range subs {
+		rec.Notified = append(rec.Notified, sub.ref())
+	}
+
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	s.Occurrences = append(s.Occurrences, rec)
+	s.emit(&Change{Op: OpOccurrence, Record: rec})
+	s.limitOccurrencesLocked()
+
+	return rec.ID, subs, nil
+}
+
+// Acknowledge records that a subscriber acknowledged an occurrence, and stops escalating it.
+// Only a subscriber who was notified of it, or an admin, may acknowledge it. If the subscriber
+// set an ack pause on the subscription, the subscription is paused. Occurrences stay in the
+// history after their escalation ends, and may still be acknowledged until they are pruned.
+// Returns ErrOccurrenceNotFound for an unknown or pruned ID, or ErrNotNotified.
+func (s *Subscribe) Acknowledge(occurrenceID string, sub *Subscriber) error {
+	event, err := s.respond(occurrenceID, sub, &Response{Action: ResponseAck})
+	if err != nil {
+		return err
+	}
+
+	if d, ok := sub.Events.RuleGetD(event, RuleAckPause); ok && d > 0 {
+		return sub.Events.Pause(sub.Events.Match(event), d)
+	}
+
+	return nil
+}
+
+// Snooze records that a subscriber wants to be reminded of an occurrence after d.
+// Reminders returns the reminder once d has passed, unless they acknowledge it first.
+// Snoozing does not stop an escalation. Returns the same errors as Acknowledge.
+func (s *Subscribe) Snooze(occurrenceID string, sub *Subscriber, d time.Duration) error {
+	_, err := s.respond(occurrenceID, sub, &Response{Action: ResponseSnooze, Until: time.Now().Add(d)})
+
+	return err
+}
+
+// Reminders returns the snoozes that ended at or before now, once each.
+func (s *Subscribe) Reminders(now time.Time) []*Reminder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	reminders := []*Reminder{}
+
+	for _, rec := range s.Occurrences {
+		changed := false
+
+		for _, resp := range rec.Responses {
+			if resp.Action != ResponseSnooze || resp.Reminded || now.Before(resp.Until) ||
+				rec.acknowledged(&resp.Subscriber, resp.At) {
+				continue
+			}
+
+			resp.Reminded, changed = true, true
+
+			ref := resp.Subscriber
+			if sub := s.findSubscriberLocked(ref.ID, ref.Contact, ref.API); sub != nil {
+				reminders = append(reminders, &Reminder{ID: rec.ID, Event: rec.Occurrence.Event, Subscriber: sub})
+			}
+		}
+
+		if changed {
+			s.emit(&Change{Op: OpOccurrence, Record: rec})
+		}
+	}
+
+	return reminders
+}
+
+// GetOccurrence returns a copy of an occurrence record.
+func (s *Subscribe) GetOccurrence(occurrenceID string) (*OccurrenceRecord, error) {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	if rec := s.occurrenceLocked(occurrenceID); rec != nil {
+		return cloneOccurrenceRecord(rec), nil
+	}
+
+	return nil, ErrOccurrenceNotFound
+}
+
+// OccurrenceHistory returns copies of the occurrence records a subscriber was notified of or
+// responded to, oldest first. A nil subscriber matches everyone, and an empty event matches
+// every event.
+func (s *Subscribe) OccurrenceHistory(sub *Subscriber, event string) []*OccurrenceRecord {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	history := []*OccurrenceRecord{}
+
+	for _, rec := range s.Occurrences {
+		if event != "" && rec.Occurrence.Event != event {
+			continue
+		}
+
+		if sub != nil && !slices.ContainsFunc(rec.Notified, sub.matches) &&
+			!slices.ContainsFunc(rec.Responses, func(resp *Response) bool { return sub.matches(&resp.Subscriber) }) {
+			continue
+		}
+
+		history = append(history, cloneOccurrenceRecord(rec))
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Occurrence.Time.Before(history[j].Occurrence.Time)
+	})
+
+	return history
+}
+
+// PruneOccurrences deletes the records of occurrences before a time, except ones still being
+// escalated. Returns the number deleted.
+func (s *Subscribe) PruneOccurrences(before time.Time) int {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	count := 0
+
+	s.Occurrences = slices.DeleteFunc(s.Occurrences, func(rec *OccurrenceRecord) bool {
+		if !rec.Occurrence.Time.Before(before) || s.escalationLocked(rec.ID) >= 0 {
+			return false
+		}
+
+		count++
+		s.emit(&Change{Op: OpPrune, Record: &OccurrenceRecord{ID: rec.ID}})
+
+		return true
+	})
+
+	return count
+}
+
+// respond records a response and, for an acknowledgement, ends the escalation.
+// Returns the occurrence's event.
+func (s *Subscribe) respond(occurrenceID string, sub *Subscriber, resp *Response) (string, error) {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	rec := s.occurrenceLocked(occurrenceID)
+	escIdx := s.escalationLocked(occurrenceID)
+
+	if rec == nil && escIdx < 0 {
+		return "", ErrOccurrenceNotFound
+	}
+
+	notified := sub.Admin || (rec != nil && slices.ContainsFunc(rec.Notified, sub.matches)) ||
+		(escIdx >= 0 && slices.ContainsFunc(s.Escalations[escIdx].Notified, sub.matches))
+	if !notified {
+		return "", ErrNotNotified
+	}
+
+	var event string
+
+	if escIdx >= 0 {
+		event = s.Escalations[escIdx].Occurrence.Event
+	}
+
+	if rec != nil {
+		event = rec.Occurrence.Event
+		resp.Subscriber, resp.At = *sub.ref(), time.Now()
+		rec.Responses = append(rec.Responses, resp)
+		s.emit(&Change{Op: OpOccurrence, Record: rec})
+	}
+
+	if escIdx >= 0 && resp.Action == ResponseAck {
+		s.endEscalationLocked(occurrenceID)
+	}
+
+	return event, nil
+}
+
+// noteEscalationLocked records who an escalation notified. Call with escalationMu held.
+func (s *Subscribe) noteEscalationLocked(esc *Escalation) {
+	rec := s.occurrenceLocked(esc.ID)
+	if rec == nil {
+		rec = &OccurrenceRecord{ID: esc.ID, Occurrence: cloneEscalation(esc).Occurrence}
+		s.Occurrences = append(s.Occurrences, rec)
+	}
+
+	rec.Notified = cloneRefs(esc.Notified)
+	s.emit(&Change{Op: OpOccurrence, Record: rec})
+	s.limitOccurrencesLocked()
+}
+
+// limitOccurrencesLocked prunes the oldest occurrence records over the limit, except ones
+// still being escalated. Call with escalationMu held.
+func (s *Subscribe) limitOccurrencesLocked() {
+	limit := s.occurrenceLimit
+	if limit <= 0 {
+		limit = DefaultOccurrenceLimit
+	}
+
+	over := len(s.Occurrences) - limit
+
+	s.Occurrences = slices.DeleteFunc(s.Occurrences, func(rec *OccurrenceRecord) bool {
+		if over <= 0 || s.escalationLocked(rec.ID) >= 0 {
+			return false
+		}
+
+		over--
+		s.emit(&Change{Op: OpPrune, Record: &OccurrenceRecord{ID: rec.ID}})
+
+		return true
+	})
+}
+
+// occurrenceLocked finds an occurrence record. Call with escalationMu held.
+func (s *Subscribe) occurrenceLocked(occurrenceID string) *OccurrenceRecord {
+	for _, rec := range s.Occurrences {
+		if rec.ID == occurrenceID {
+			return rec
+		}
+	}
+
+	return nil
+}
+
+// escalationLocked returns the index of an escalation, or -1. Call with escalationMu held.
+func (s *Subscribe) escalationLocked(occurrenceID string) int {
+	return slices.IndexFunc(s.Escalations, func(esc *Escalation) bool { return esc.ID == occurrenceID })
+}
+
+// acknowledged returns true if the subscriber acknowledged the occurrence after a time.
+func (o *OccurrenceRecord) acknowledged(ref *SubscriberRef, after time.Time) bool {
+	return slices.ContainsFunc(o.Responses, func(resp *Response) bool {
+		return resp.Action == ResponseAck && resp.Subscriber == *ref && !resp.At.Before(after)
+	})
+}
+
+// applyOccurrence replays an occurrence record change.
+func (s *Subscribe) applyOccurrence(record *Change) {
+	if record.Record == nil {
+		return
+	}
+
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	idx := slices.IndexFunc(s.Occurrences, func(rec *OccurrenceRecord) bool { return rec.ID == record.Record.ID })
+
+	switch {
+	case record.Op == OpPrune && idx >= 0:
+		s.Occurrences = slices.Delete(s.Occurrences, idx, idx+1)
+	case record.Op == OpOccurrence && record.Record.Occurrence != nil && idx >= 0:
+		s.Occurrences[idx] = cloneOccurrenceRecord(record.Record)
+	case record.Op == OpOccurrence && record.Record.Occurrence != nil:
+		s.Occurrences = append(s.Occurrences, cloneOccurrenceRecord(record.Record))
+	}
+}
+
+func (s *Subscribe) snapshotOccurrences() []*OccurrenceRecord {
+	s.escalationMu.Lock()
+	defer s.escalationMu.Unlock()
+
+	if len(s.Occurrences) == 0 {
+		return nil
+	}
+
+	out := make([]*OccurrenceRecord, 0, len(s.Occurrences))
+	for _, rec := range s.Occurrences {
+		out = append(out, cloneOccurrenceRecord(rec))
+	}
+
+	return out
+}
+
+func cloneOccurrenceRecord(rec *OccurrenceRecord) *OccurrenceRecord {
+	out := *rec
+	occurrence := *rec.Occurrence
+	occurrence.Attrs = maps.Clone(rec.Occurrence.Attrs)
+	out.Occurrence = &occurrence
+	out.Notified = cloneRefs(rec.Notified)
+	out.Responses = make([]*Response, 0, len(rec.Responses))
+
+	for _, resp := range rec.Responses {
+		cloned := *resp
+		out.Responses = append(out.Responses, &cloned)
+	}
+
+	return &out
+}
+
+func cloneRefs(refs []*SubscriberRef) []*SubscriberRef {
+	out := make([]*SubscriberRef, 0, len(refs))
+
+	for _, ref := range refs {
+		cloned := *ref
+		out = append(out, &cloned)
+	}
+
+	return out
+}
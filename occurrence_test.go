@@ -0,0 +1,156 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchAcknowledge(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+
+	first := sub.CreateSub("first", "api", false, false)
+	second := sub.CreateSub("second", "api", false, false)
+	outsider := sub.CreateSub("outsider", "api", false, false)
+
+	require.NoError(t, first.Subscribe("motion"))
+	require.NoError(t, second.Subscribe("motion"))
+	first.Events.SetAckPause("motion", time.Hour)
+
+	id, subs, err := sub.Dispatch(&Occurrence{Event: "motion"})
+	require.NoError(t, err)
+	assertions.NotEmpty(id)
+	assertions.Equal([]*Subscriber{first, second}, subs)
+
+	require.ErrorIs(t, sub.Acknowledge(id, outsider), ErrNotNotified)
+	require.ErrorIs(t, sub.Snooze("missing", first, time.Minute), ErrOccurrenceNotFound)
+
+	require.NoError(t, sub.Acknowledge(id, first))
+	assertions.True(first.Events.IsPaused("motion"), "the ack pause must pause the subscription")
+	assertions.False(second.Events.IsPaused("motion"))
+
+	require.NoError(t, sub.Snooze(id, second, time.Minute))
+	assertions.Empty(sub.Reminders(time.Now()))
+
+	reminders := sub.Reminders(time.Now().Add(time.Minute))
+	require.Len(t, reminders, 1)
+	assertions.Equal(id, reminders[0].ID)
+	assertions.Same(second, reminders[0].Subscriber)
+	assertions.Empty(sub.Reminders(time.Now().Add(time.Hour)), "reminders are returned once")
+
+	rec, err := sub.GetOccurrence(id)
+	require.NoError(t, err)
+	require.Len(t, rec.Responses, 2)
+	assertions.Equal(ResponseAck, rec.Responses[0].Action)
+	assertions.Equal("first", rec.Responses[0].Subscriber.Contact)
+	assertions.Equal(ResponseSnooze, rec.Responses[1].Action)
+
+	second.Events.RuleDelD("motion", RuleAckPause)
+	id2, _, err := sub.Dispatch(&Occurrence{Event: "motion"})
+	require.NoError(t, err)
+	require.NoError(t, sub.Snooze(id2, second, time.Minute))
+	require.NoError(t, sub.Acknowledge(id2, second))
+	assertions.Empty(sub.Reminders(time.Now().Add(time.Hour)), "an acknowledged snooze must not remind")
+
+	assertions.Len(sub.OccurrenceHistory(nil, ""), 2)
+	assertions.Len(sub.OccurrenceHistory(first, "motion"), 1, "first was paused for the second occurrence")
+	assertions.Len(sub.OccurrenceHistory(second, ""), 2)
+	assertions.Empty(sub.OccurrenceHistory(outsider, ""))
+	assertions.Empty(sub.OccurrenceHistory(nil, "other"))
+
+	assertions.Equal(2, sub.PruneOccurrences(time.Now()))
+	assertions.Empty(sub.OccurrenceHistory(nil, ""))
+}
+
+func TestOccurrenceLimit(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+
+	id, subs, err := sub.Dispatch(&Occurrence{Event: "nobody"})
+	require.NoError(t, err)
+	assertions.Empty(subs)
+	assertions.Empty(id, "an occurrence nobody was notified of must not be recorded")
+	assertions.Empty(sub.OccurrenceHistory(nil, ""))
+
+	sub.SetOccurrenceLimit(2)
+
+	ids := make([]string, 0, 3)
+
+	for range 3 {
+		id, _, err = sub.Dispatch(&Occurrence{Event: "motion"})
+		require.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	history := sub.OccurrenceHistory(nil, "")
+	require.Len(t, history, 2, "the oldest occurrence must be pruned")
+	assertions.Equal(ids[1], history[0].ID)
+	assertions.Equal(ids[2], history[1].ID)
+	require.ErrorIs(t, sub.Acknowledge(ids[0], user), ErrOccurrenceNotFound)
+
+	sub.SetOccurrenceLimit(1)
+	assertions.Len(sub.OccurrenceHistory(nil, ""), 1, "lowering the limit must prune")
+}
+
+func TestEscalationAcknowledgeHistory(t *testing.T) {
+	t.Parallel()
+
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("alert"))
+	policy := &EscalationPolicy{Steps: []*EscalationStep{
+		{Target: EscalateSubscribers},
+		{After: time.Hour, Target: EscalateAdmins},
+	}}
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "alert", Escalation: policy}))
+
+	id, _, err := sub.Escalate(&Occurrence{Event: "alert"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, sub.PruneOccurrences(time.Now().Add(time.Hour)), "escalating occurrences are kept")
+
+	require.NoError(t, sub.Acknowledge(id, user))
+	assert.Empty(t, sub.GetEscalations())
+
+	history := sub.OccurrenceHistory(user, "alert")
+	require.Len(t, history, 1)
+	assert.Equal(t, id, history[0].ID)
+	assert.Equal(t, ResponseAck, history[0].Responses[0].Action)
+}
+
+func TestOccurrencesPersist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	user := sub.CreateSub("user", "api", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+
+	id, _, err := sub.Dispatch(&Occurrence{Event: "motion", Attrs: map[string]any{"camera": "front"}})
+	require.NoError(t, err)
+	require.NoError(t, sub.Snooze(id, user, time.Minute))
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+
+	rec, err := loaded.GetOccurrence(id)
+	require.NoError(t, err)
+	assert.Equal(t, "front", rec.Occurrence.Attrs["camera"])
+	require.Len(t, rec.Responses, 1)
+	assert.Equal(t, ResponseSnooze, rec.Responses[0].Action)
+	assert.Len(t, loaded.Reminders(time.Now().Add(time.Hour)), 1)
+}
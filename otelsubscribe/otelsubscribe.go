@@ -0,0 +1,61 @@
+// Package otelsubscribe traces golift.io/subscribe database operations with OpenTelemetry.
+//
+//	db.SetTracer(otelsubscribe.NewTracer(otel.Tracer("golift.io/subscribe")))
+package otelsubscribe
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golift.io/subscribe"
+)
+
+// Tracer is a subscribe.Tracer that starts OpenTelemetry spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// Span is a subscribe.Span wrapping an OpenTelemetry span.
+type Span struct {
+	span trace.Span
+}
+
+// NewTracer returns a subscribe.Tracer that starts spans with an OpenTelemetry tracer.
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// Start begins an internal span, a child of any span in ctx.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, subscribe.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+
+	return ctx, &Span{span: span}
+}
+
+// SetAttribute records a value on the span. Values other than strings, ints and bools
+// are recorded as strings.
+func (s *Span) SetAttribute(key string, value any) {
+	switch val := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, val))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, val))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, val))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(val)))
+	}
+}
+
+// End records err on the span, if not nil, and ends it.
+func (s *Span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+
+	s.span.End()
+}
@@ -0,0 +1,51 @@
+package otelsubscribe_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golift.io/subscribe"
+	"golift.io/subscribe/otelsubscribe"
+)
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	sub, err := subscribe.GetDB(filepath.Join(t.TempDir(), "subscribers.json"))
+	require.NoError(t, err)
+
+	sub.SetTracer(otelsubscribe.NewTracer(provider.Tracer("test")))
+	require.NoError(t, sub.CreateSub("user", "pushover", false, false).Subscribe("motion"))
+
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "request")
+	assertions.Len(sub.GetSubscribersContext(ctx, "motion"), 1)
+
+	_, err = sub.GetOccurrenceSubscribersContext(ctx, &subscribe.Occurrence{Event: "motion", Severity: "bogus"})
+	require.ErrorIs(t, err, subscribe.ErrUnknownSeverity)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	assertions.Equal("subscribe.GetSubscribers", spans[0].Name())
+	assertions.Equal(parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assertions.ElementsMatch([]attribute.KeyValue{
+		attribute.String(subscribe.SpanAttrEvent, "motion"),
+		attribute.Int(subscribe.SpanAttrSubscribers, 1),
+	}, spans[0].Attributes())
+	assertions.Equal(codes.Unset, spans[0].Status().Code)
+
+	assertions.Equal("subscribe.GetOccurrenceSubscribers", spans[1].Name())
+	assertions.Equal(codes.Error, spans[1].Status().Code)
+	assertions.Len(spans[1].Events(), 1, "the error is recorded")
+}
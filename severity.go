@@ -0,0 +1,127 @@
+package subscribe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+/**************************
+ *    Severity Methods    *
+ **************************/
+
+// RuleMinSeverity is the string rule holding a subscription's minimum severity level.
+// Set it with SetMinSeverity.
+const RuleMinSeverity = "minSeverity"
+
+// Occurrence is a single firing of an event.
+type Occurrence struct {
+	// Event is the event name, or an alias of it.
+	Event string `json:"event"`
+	// Severity is a level from the Severities list. When empty, the event definition's
+	// severity is used. When both are empty, no severity filtering happens.
+	Severity string `json:"severity,omitempty"`
+	// Attrs are checked against subscription filters, like GetSubscribersFor.
+	Attrs map[string]any `json:"attrs,omitempty"`
+	// Time is when the event occurred, for digests and occurrence records; when zero, the current time is used.
+	Time time.Time `json:"time,omitzero"`
+}
+
+// SetSeverities replaces the list of valid severity levels. Provide them lowest first,
+// like: SetSeverities("info", "warning", "critical").
+func (s *Subscribe) SetSeverities(levels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Severities = slices.Clone(levels)
+	s.emit(&Change{Op: OpSeverities, Levels: s.Severities})
+}
+
+// SeverityLevels returns a copy of the valid severity levels, lowest first.
+func (s *Subscribe) SeverityLevels() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.Severities)
+}
+
+// SetMinSeverity sets the lowest severity an event subscription is notified of.
+// Pass a subscriber's or group's Events. An empty level removes the threshold.
+// Returns ErrUnknownSeverity if level is not in the Severities list.
+func (s *Subscribe) SetMinSeverity(events *Events, event, level string) error {
+	if level == "" {
+		events.RuleDelS(event, RuleMinSeverity)
+
+		return nil
+	}
+
+	s.mu.RLock()
+	valid := slices.Contains(s.Severities, level)
+	s.mu.RUnlock()
+
+	if !valid {
+		return fmt.Errorf("%w: %s", ErrUnknownSeverity, level)
+	}
+
+	events.RuleSetS(event, RuleMinSeverity, level)
+
+	return nil
+}
+
+// GetOccurrenceSubscribers works like GetSubscribersFor, and also skips subscribers, and groups,
+// whose subscription has a minimum severity above the occurrence's severity. A minimum
+// severity missing from the Severities list is ignored. Returns ErrUnknownSeverity if the
+// occurrence's severity is not in the Severities list.
+func (s *Subscribe) GetOccurrenceSubscribers(occurrence *Occurrence) ([]*Subscriber, error) {
+	return s.GetOccurrenceSubscribersContext(context.Background(), occurrence)
+}
+
+// GetOccurrenceSubscribersContext works like GetOccurrenceSubscribers, in a span of ctx's trace.
+// See SetTracer.
+func (s *Subscribe) GetOccurrenceSubscribersContext(
+	ctx context.Context, occurrence *Occurrence,
+) ([]*Subscriber, error) {
+	return s.traceSubscribers(ctx, "GetOccurrenceSubscribers", occurrence.Event, func() ([]*Subscriber, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		return s.occurrenceSubscribersLocked(occurrence, lookupQuery)
+	})
+}
+
+// occurrenceSubscribersLocked returns the subscribers for an occurrence. Digest subscriptions
+// are skipped, and collect the occurrence for lookupDispatch. Call with mu held.
+func (s *Subscribe) occurrenceSubscribersLocked(occurrence *Occurrence, kind lookup) ([]*Subscriber, error) {
+	resolved := s.resolveOccurrenceLocked(occurrence)
+
+	accept, err := s.severityAcceptLocked(resolved, filterAccept(resolved.Event, resolved.Attrs))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.subscribersLocked(resolved, accept, kind), nil
+}
+
+// severityAcceptLocked returns an accept function for subscribersLocked that also skips
+// subscriptions whose minimum severity is above a resolved occurrence's severity. accept may
+// be nil. Returns ErrUnknownSeverity if the severity is not in the Severities list.
+// Call with mu held.
+func (s *Subscribe) severityAcceptLocked(
+	occurrence *Occurrence, accept func(*Events) bool,
+) (func(*Events) bool, error) {
+	if occurrence.Severity == "" {
+		return accept, nil
+	}
+
+	rank := slices.Index(s.Severities, occurrence.Severity)
+	if rank < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSeverity, occurrence.Severity)
+	}
+
+	return func(events *Events) bool {
+		level, _ := events.RuleGetS(occurrence.Event, RuleMinSeverity)
+
+		return slices.Index(s.Severities, level) <= rank && (accept == nil || accept(events))
+	}, nil
+}
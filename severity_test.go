@@ -0,0 +1,82 @@
+package subscribe
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOccurrenceSubscribers(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: &Events{Map: make(map[string]*Rules)}}
+	sub.SetSeverities("info", "warning", "critical")
+
+	all := sub.CreateSub("all", "api", false, false)
+	critical := sub.CreateSub("critical", "api", false, false)
+	member := sub.CreateSub("member", "api", false, false)
+
+	for _, s := range []*Subscriber{all, critical} {
+		require.NoError(t, s.Subscribe("camera.#"))
+	}
+
+	require.NoError(t, sub.SetMinSeverity(critical.Events, "camera.#", "critical"))
+	require.ErrorIs(t, sub.SetMinSeverity(all.Events, "camera.#", "bogus"), ErrUnknownSeverity)
+
+	group := sub.CreateGroup("oncall")
+	group.AddMember(member)
+	require.NoError(t, group.Subscribe("camera.motion"))
+	require.NoError(t, sub.SetMinSeverity(group.Events, "camera.motion", "warning"))
+
+	subs, err := sub.GetOccurrenceSubscribers(&Occurrence{Event: "camera.motion", Severity: "info"})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{all}, subs)
+
+	subs, err = sub.GetOccurrenceSubscribers(&Occurrence{Event: "camera.motion", Severity: "warning"})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{all, member}, subs)
+
+	subs, err = sub.GetOccurrenceSubscribers(&Occurrence{Event: "camera.motion", Severity: "critical"})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{all, critical, member}, subs)
+
+	_, err = sub.GetOccurrenceSubscribers(&Occurrence{Event: "camera.motion", Severity: "bogus"})
+	require.ErrorIs(t, err, ErrUnknownSeverity)
+
+	subs, err = sub.GetOccurrenceSubscribers(&Occurrence{Event: "camera.motion"})
+	require.NoError(t, err)
+	assertions.Equal(sub.GetSubscribers("camera.motion"), subs, "no severity means no filtering")
+
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "camera.motion", Severity: "info"}))
+	subs, err = sub.GetOccurrenceSubscribers(&Occurrence{Event: "camera.motion"})
+	require.NoError(t, err)
+	assertions.Equal([]*Subscriber{all}, subs, "the definition's severity must be the default")
+	assertions.Equal(subs, sub.GetSubscribers("camera.motion"), "GetSubscribers must use the definition's severity")
+
+	require.NoError(t, sub.DefineEvent(&EventDefinition{Name: "camera.motion", Severity: "bogus"}))
+	assertions.Equal([]*Subscriber{all, critical, member}, sub.GetSubscribers("camera.motion"),
+		"an unknown definition severity must not filter GetSubscribers")
+
+	require.NoError(t, sub.SetMinSeverity(critical.Events, "camera.#", ""))
+	_, found := critical.Events.RuleGetS("camera.#", RuleMinSeverity)
+	assertions.False(found)
+}
+
+func TestSeveritiesPersist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+
+	sub.SetSeverities("low", "high")
+	require.NoError(t, sub.JournalDisable())
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"low", "high"}, loaded.SeverityLevels())
+}
@@ -0,0 +1,578 @@
+// Package sqlitestore persists golift.io/subscribe databases in SQLite, with a pure-Go
+// driver, so it builds without cgo. Subscribers, subscriptions and their rules are kept
+// in tables and updated on every change. Everything else, like groups, definitions and
+// digests, is kept as JSON and the changes to it are recorded until the next save, or
+// until FoldChanges of them are folded into it.
+// Importing this package makes GetDB open files ending in .sqlite with a Store:
+//
+//	import _ "golift.io/subscribe/sqlitestore"
+//
+//	db, err := subscribe.GetDB("/var/lib/app/subscribers.sqlite")
+//	defer db.Close()
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golift.io/subscribe"
+	_ "modernc.org/sqlite" // Registers the sqlite driver.
+)
+
+// ErrBadRule is returned when a rule in the database has an unknown type or an invalid value.
+var ErrBadRule = errors.New("invalid rule")
+
+// The owner columns identify whose Events a subscription belongs to: the database's (all empty),
+// a group's (grp) or a subscriber's (api and sub_id, or api and contact when sub_id is 0).
+const schema = `
+CREATE TABLE IF NOT EXISTS subscribers (
+	seq       INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace TEXT NOT NULL,
+	api       TEXT NOT NULL,
+	sub_id    INTEGER NOT NULL,
+	contact   TEXT NOT NULL,
+	admin     INTEGER NOT NULL,
+	ignored   INTEGER NOT NULL,
+	meta      TEXT
+);
+CREATE TABLE IF NOT EXISTS subscriptions (
+	namespace TEXT NOT NULL,
+	grp       TEXT NOT NULL,
+	api       TEXT NOT NULL,
+	sub_id    INTEGER NOT NULL,
+	contact   TEXT NOT NULL,
+	event     TEXT NOT NULL,
+	pause     TEXT NOT NULL,
+	PRIMARY KEY (namespace, grp, api, sub_id, contact, event)
+);
+CREATE TABLE IF NOT EXISTS rules (
+	namespace TEXT NOT NULL,
+	grp       TEXT NOT NULL,
+	api       TEXT NOT NULL,
+	sub_id    INTEGER NOT NULL,
+	contact   TEXT NOT NULL,
+	event     TEXT NOT NULL,
+	kind      TEXT NOT NULL,
+	rule      TEXT NOT NULL,
+	value     TEXT NOT NULL,
+	PRIMARY KEY (namespace, grp, api, sub_id, contact, event, kind, rule)
+);
+CREATE TABLE IF NOT EXISTS state (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS changes (
+	seq    INTEGER PRIMARY KEY AUTOINCREMENT,
+	record TEXT NOT NULL
+);`
+
+const (
+	ownerColumns = "namespace, grp, api, sub_id, contact"
+	ownerWhere   = "namespace = ? AND grp = ? AND api = ? AND sub_id = ? AND contact = ?"
+)
+
+// FoldChanges is the number of recorded changes Write folds into the saved JSON,
+// so the changes table does not grow without bound between saves.
+const FoldChanges = 1000
+
+// Store is a subscribe.Store backed by a SQLite database file.
+type Store struct {
+	db *sql.DB
+	// foldAt is the number of recorded changes folded into the saved JSON.
+	foldAt int
+}
+
+// owner identifies whose Events a subscription belongs to.
+type owner struct {
+	namespace string
+	group     string
+	api       string
+	id        int64
+	contact   string
+}
+
+// Extension is the file extension GetDB opens with a Store.
+const Extension = ".sqlite"
+
+func init() {
+	subscribe.RegisterStore(Extension, func(path string) (subscribe.Store, error) { return Open(path) })
+}
+
+// Open opens, or creates, a SQLite database file and its tables.
+func Open(path string) (*Store, error) {
+	// A URI keeps characters like ? and # in the path from being read as parameters.
+	pragmas := url.Values{"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)"}}
+
+	db, err := sql.Open("sqlite", "file:"+url.PathEscape(path)+"?"+pragmas.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	// SQLite allows one writer at a time; this avoids waiting on ourselves.
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating tables: %w", err)
+	}
+
+	return &Store{db: db, foldAt: FoldChanges}, nil
+}
+
+// Close closes the database file.
+func (s *Store) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("closing database: %w", err)
+	}
+
+	return nil
+}
+
+// Import replaces the contents of the store with a state file written by StateFileSave.
+// Call StateFileLoad on databases already using the store to pick it up.
+func (s *Store) Import(ctx context.Context, stateFile string) error {
+	return subscribe.StoreImport(ctx, s, stateFile)
+}
+
+// Load returns the database: the JSON written by Save, with the recorded changes replayed,
+// then the subscribers, subscriptions and rules from their tables.
+func (s *Store) Load(ctx context.Context) (*subscribe.Subscribe, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	db, err := loadState(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := loadSubscribers(ctx, tx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = loadSubscriptions(ctx, tx, events); err != nil {
+		return nil, err
+	}
+
+	return db, loadRules(ctx, tx, events)
+}
+
+// loadState returns the JSON written by Save, with the recorded changes replayed.
+func loadState(ctx context.Context, tx *sql.Tx) (*subscribe.Subscribe, error) {
+	db := new(subscribe.Subscribe)
+
+	var data string
+
+	err := tx.QueryRowContext(ctx, "SELECT data FROM state WHERE id = 1").Scan(&data)
+	if err == nil {
+		err = json.Unmarshal([]byte(data), db)
+	} else if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading state: %w", err)
+	}
+
+	changes, err := loadChanges(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.Replay(changes...)
+
+	return db, nil
+}
+
+func loadChanges(ctx context.Context, tx *sql.Tx) ([]*subscribe.Change, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT record FROM changes ORDER BY seq")
+	if err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []*subscribe.Change{}
+
+	for rows.Next() {
+		var record string
+
+		change := new(subscribe.Change)
+
+		if err = rows.Scan(&record); err == nil {
+			err = json.Unmarshal([]byte(record), change)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading changes: %w", err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// loadSubscribers adds the subscribers to their namespaces. Returns every Events map by owner.
+func loadSubscribers(ctx context.Context, tx *sql.Tx, db *subscribe.Subscribe) (map[owner]*subscribe.Events, error) {
+	events := make(map[owner]*subscribe.Events)
+
+	addNamespace := func(name string, ns *subscribe.Subscribe) {
+		events[owner{namespace: name}] = ns.Events
+		for group, grp := range ns.Groups {
+			events[owner{namespace: name, group: group}] = grp.Events
+		}
+	}
+
+	addNamespace("", db)
+
+	for name, ns := range db.Namespaces {
+		addNamespace(name, ns)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT namespace, api, sub_id, contact, admin, ignored, meta FROM subscribers ORDER BY seq")
+	if err != nil {
+		return nil, fmt.Errorf("reading subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name string
+			meta sql.NullString
+			sub  = &subscribe.Subscriber{Events: &subscribe.Events{Map: make(map[string]*subscribe.Rules)}}
+		)
+
+		err = rows.Scan(&name, &sub.API, &sub.ID, &sub.Contact, &sub.Admin, &sub.Ignored, &meta)
+		if err == nil && meta.Valid {
+			err = json.Unmarshal([]byte(meta.String), &sub.Meta)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading subscribers: %w", err)
+		}
+
+		ns := db.Namespace(name)
+		if _, ok := events[owner{namespace: name}]; !ok {
+			addNamespace(name, ns)
+		}
+
+		ns.Subscribers = append(ns.Subscribers, sub)
+		events[subscriberOwner(name, sub.API, sub.ID, sub.Contact)] = sub.Events
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading subscribers: %w", err)
+	}
+
+	return events, nil
+}
+
+func loadSubscriptions(ctx context.Context, tx *sql.Tx, events map[owner]*subscribe.Events) error {
+	rows, err := tx.QueryContext(ctx, "SELECT "+ownerColumns+", event, pause FROM subscriptions")
+	if err != nil {
+		return fmt.Errorf("reading subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			own          owner
+			event, pause string
+		)
+
+		if err = rows.Scan(&own.namespace, &own.group, &own.api, &own.id, &own.contact, &event, &pause); err != nil {
+			return fmt.Errorf("reading subscriptions: %w", err)
+		}
+
+		rules := &subscribe.Rules{
+			D: make(map[string]time.Duration),
+			I: make(map[string]int),
+			S: make(map[string]string),
+			T: make(map[string]time.Time),
+		}
+
+		if rules.Pause, err = parseTime(pause); err != nil {
+			return fmt.Errorf("reading subscriptions: %w", err)
+		}
+
+		// Subscriptions of removed groups and subscribers are deleted with them; skip any stragglers.
+		if owned, ok := events[own]; ok {
+			owned.Map[event] = rules
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("reading subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+func loadRules(ctx context.Context, tx *sql.Tx, events map[owner]*subscribe.Events) error {
+	rows, err := tx.QueryContext(ctx, "SELECT "+ownerColumns+", event, kind, rule, value FROM rules")
+	if err != nil {
+		return fmt.Errorf("reading rules: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			own                      owner
+			event, kind, rule, value string
+		)
+
+		err = rows.Scan(&own.namespace, &own.group, &own.api, &own.id, &own.contact, &event, &kind, &rule, &value)
+		if err != nil {
+			return fmt.Errorf("reading rules: %w", err)
+		}
+
+		owned, ok := events[own]
+		if !ok || owned.Map[event] == nil {
+			continue
+		}
+
+		if err = setRule(owned.Map[event], kind, rule, value); err != nil {
+			return fmt.Errorf("reading rules: %s %s: %w", event, rule, err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("reading rules: %w", err)
+	}
+
+	return nil
+}
+
+// Save replaces everything in the database with db.
+func (s *Store) Save(ctx context.Context, db *subscribe.Subscribe) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"subscribers", "subscriptions", "rules", "state", "changes"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("emptying %s: %w", table, err)
+		}
+	}
+
+	if err = saveNamespace(ctx, tx, "", db); err != nil {
+		return err
+	}
+
+	for name, ns := range db.Namespaces {
+		if err = saveNamespace(ctx, tx, name, ns); err != nil {
+			return err
+		}
+	}
+
+	// Everything in the tables was removed from db, so the rest is saved as JSON.
+	data, err := json.Marshal(db)
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "INSERT INTO state (id, data) VALUES (1, ?)", string(data)); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// saveNamespace writes the subscribers and subscriptions in a namespace to their tables,
+// and removes them from ns.
+func saveNamespace(ctx context.Context, tx *sql.Tx, name string, ns *subscribe.Subscribe) error {
+	for _, sub := range ns.Subscribers {
+		if err := insertSubscriber(ctx, tx, name, sub); err != nil {
+			return err
+		}
+
+		if err := insertEvents(ctx, tx, subscriberOwner(name, sub.API, sub.ID, sub.Contact), sub.Events); err != nil {
+			return err
+		}
+	}
+
+	if err := insertEvents(ctx, tx, owner{namespace: name}, ns.Events); err != nil {
+		return err
+	}
+
+	for group, grp := range ns.Groups {
+		if err := insertEvents(ctx, tx, owner{namespace: name, group: group}, grp.Events); err != nil {
+			return err
+		}
+
+		grp.Events = nil
+	}
+
+	ns.Subscribers, ns.Events = nil, nil
+
+	return nil
+}
+
+func insertSubscriber(ctx context.Context, tx *sql.Tx, namespace string, sub *subscribe.Subscriber) error {
+	var meta sql.NullString
+
+	if sub.Meta != nil {
+		buf, err := json.Marshal(sub.Meta)
+		if err != nil {
+			return fmt.Errorf("encoding subscriber meta: %w", err)
+		}
+
+		meta = sql.NullString{String: string(buf), Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO subscribers
+		(namespace, api, sub_id, contact, admin, ignored, meta) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		namespace, sub.API, sub.ID, sub.Contact, sub.Admin, sub.Ignored, meta)
+	if err != nil {
+		return fmt.Errorf("writing subscriber: %w", err)
+	}
+
+	return nil
+}
+
+func insertEvents(ctx context.Context, tx *sql.Tx, own owner, events *subscribe.Events) error {
+	if events == nil {
+		return nil
+	}
+
+	for event, rules := range events.Map {
+		if err := insertSubscription(ctx, tx, own, event, rules); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertSubscription(ctx context.Context, tx *sql.Tx, own owner, event string, rules *subscribe.Rules) error {
+	if rules == nil {
+		rules = &subscribe.Rules{}
+	}
+
+	err := execEach(ctx, tx, "writing subscription", append(own.args(), event, formatTime(rules.Pause)),
+		"INSERT INTO subscriptions ("+ownerColumns+", event, pause) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	for kind, values := range ruleValues(rules) {
+		for rule, value := range values {
+			if err = putRule(ctx, tx, own, event, kind, rule, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// subscriberOwner returns the owner of a subscriber's subscriptions. Subscribers with an ID
+// are matched by it, so their contact is not part of the key.
+func subscriberOwner(namespace, api string, id int64, contact string) owner {
+	if id != 0 {
+		contact = ""
+	}
+
+	return owner{namespace: namespace, api: api, id: id, contact: contact}
+}
+
+func (o owner) args() []any {
+	return []any{o.namespace, o.group, o.api, o.id, o.contact}
+}
+
+// ruleValues returns a subscription's rules as text, by type.
+func ruleValues(rules *subscribe.Rules) map[string]map[string]string {
+	values := map[string]map[string]string{"D": {}, "I": {}, "S": {}, "T": {}}
+
+	for rule, val := range rules.D {
+		values["D"][rule] = strconv.FormatInt(int64(val), 10)
+	}
+
+	for rule, val := range rules.I {
+		values["I"][rule] = strconv.Itoa(val)
+	}
+
+	for rule, val := range rules.S {
+		values["S"][rule] = val
+	}
+
+	for rule, val := range rules.T {
+		values["T"][rule] = formatTime(val)
+	}
+
+	return values
+}
+
+// setRule parses a rule's text value into a subscription's rules.
+func setRule(rules *subscribe.Rules, kind, rule, value string) error {
+	switch kind {
+	case "D":
+		val, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrBadRule, err)
+		}
+
+		rules.D[rule] = time.Duration(val)
+	case "I":
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrBadRule, err)
+		}
+
+		rules.I[rule] = val
+	case "S":
+		rules.S[rule] = value
+	case "T":
+		val, err := parseTime(value)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrBadRule, err)
+		}
+
+		rules.T[rule] = val
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrBadRule, kind)
+	}
+
+	return nil
+}
+
+func formatTime(val time.Time) string {
+	if val.IsZero() {
+		return ""
+	}
+
+	return val.Format(time.RFC3339Nano)
+}
+
+func parseTime(val string) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time: %w", err)
+	}
+
+	return parsed, nil
+}
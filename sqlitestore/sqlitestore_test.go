@@ -0,0 +1,102 @@
+package sqlitestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golift.io/subscribe"
+	"golift.io/subscribe/storetest"
+)
+
+// buildDB makes changes of every kind the store writes to its tables.
+func buildDB(t *testing.T, db *subscribe.Subscribe) {
+	t.Helper()
+
+	require.NoError(t, db.Events.New("motion", &subscribe.Rules{S: map[string]string{"room": "den"}}))
+
+	user := db.CreateSub("user", "pushover", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	require.NoError(t, user.Events.Pause("motion", time.Hour))
+	user.Events.RuleSetD("motion", "delay", time.Minute)
+	user.Events.RuleSetI("motion", "count", 3)
+	user.Events.RuleSetS("motion", "sound", "bell")
+	user.Events.RuleSetT("motion", "since", time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC))
+	user.Events.RuleDelI("motion", "count")
+
+	admin := db.CreateSubWithID(7, "admin", "slack", true, false)
+	require.NoError(t, admin.Subscribe("door"))
+	require.NoError(t, admin.Subscribe("window"))
+	admin.Events.Remove("window")
+	db.CreateSubWithID(7, "admin", "slack", true, true)
+
+	group := db.CreateGroup("ops")
+	group.AddMember(user)
+	require.NoError(t, group.Events.New("door", nil))
+	group.Events.RuleSetS("door", "sound", "horn")
+
+	tenant := db.Namespace("tenant")
+	require.NoError(t, tenant.CreateSub("other", "slack", false, false).Subscribe("motion"))
+	db.NamespaceRemove("tenant")
+	require.NoError(t, db.Namespace("tenant").CreateSub("new", "slack", false, false).Subscribe("door"))
+
+	require.NoError(t, db.EventRename("motion", "movement"))
+	db.SetSeverities("info", "critical")
+}
+
+func TestTables(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+
+	store, err := Open(filepath.Join(t.TempDir(), "subscribers.sqlite"))
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	db, err := subscribe.GetStoreDB(store)
+	require.NoError(t, err)
+	buildDB(t, db)
+
+	var subscribers, rules, changes int
+
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM subscribers").Scan(&subscribers))
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM rules").Scan(&rules))
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM changes").Scan(&changes))
+	assertions.Equal(3, subscribers, "subscribers must be written as they change")
+	assertions.Equal(5, rules, "rules must be written as they change")
+	assertions.Positive(changes, "other changes must be recorded")
+
+	require.NoError(t, db.StateFileSave())
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM changes").Scan(&changes))
+	assertions.Zero(changes, "saving must fold recorded changes into the saved state")
+}
+
+func TestOpenPath(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "odd?name #1 %20")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+
+	path := filepath.Join(dir, "subscribers.sqlite")
+
+	store, err := Open(path)
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	var mode string
+
+	require.NoError(t, store.db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode, "the pragmas must be applied")
+	assert.FileExists(t, path, "the path must be used as given")
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunStore(t, Extension, func(path string) (subscribe.Store, error) { return Open(path) })
+}
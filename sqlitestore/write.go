@@ -0,0 +1,215 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"golift.io/subscribe"
+)
+
+const (
+	subscriberWhere = "namespace = ? AND api = ? AND sub_id = ? AND (sub_id != 0 OR contact = ?)"
+)
+
+// Write updates the tables for a change to subscribers and subscriptions, and records other
+// changes to be replayed by Load. Each change is written in its own transaction. Once
+// FoldChanges are recorded, they are replayed into the saved JSON and removed.
+func (s *Store) Write(change *subscribe.Change) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = write(ctx, tx, change); err != nil {
+		return err
+	}
+
+	if err = foldChanges(ctx, tx, s.foldAt); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+func write(ctx context.Context, tx *sql.Tx, change *subscribe.Change) error {
+	own := owner{namespace: change.Namespace, group: change.Group}
+	if change.Sub != nil {
+		own = subscriberOwner(change.Namespace, change.Sub.API, change.Sub.ID, change.Sub.Contact)
+	}
+
+	switch change.Op {
+	case subscribe.OpSubscriber:
+		return putSubscriber(ctx, tx, change)
+	case subscribe.OpUnsubscribe:
+		return removeSubscriber(ctx, tx, own)
+	case subscribe.OpNew:
+		if err := deleteSubscription(ctx, tx, own, change.Event); err != nil {
+			return err
+		}
+
+		return insertSubscription(ctx, tx, own, change.Event, change.Rules)
+	case subscribe.OpPause:
+		return execEach(ctx, tx, "pausing subscription",
+			append([]any{formatTime(change.Pause)}, append(own.args(), change.Event)...),
+			"UPDATE subscriptions SET pause = ? WHERE "+ownerWhere+" AND event = ?")
+	case subscribe.OpRemove:
+		return deleteSubscription(ctx, tx, own, change.Event)
+	case subscribe.OpRename:
+		return renameSubscription(ctx, tx, own, change.Event, change.To)
+	case subscribe.OpRuleSet:
+		if change.Rules == nil {
+			return nil
+		}
+
+		if value, ok := ruleValues(change.Rules)[change.Kind][change.Rule]; ok {
+			return putRule(ctx, tx, own, change.Event, change.Kind, change.Rule, value)
+		}
+
+		return nil
+	case subscribe.OpRuleDel:
+		return execEach(ctx, tx, "deleting rule", append(own.args(), change.Event, change.Rule, change.Kind, change.Kind),
+			"DELETE FROM rules WHERE "+ownerWhere+" AND event = ? AND rule = ? AND (? = '' OR kind = ?)")
+	case subscribe.OpNamespaceRemove:
+		err := execEach(ctx, tx, "removing namespace", []any{change.Namespace},
+			"DELETE FROM subscribers WHERE namespace = ?",
+			"DELETE FROM subscriptions WHERE namespace = ?",
+			"DELETE FROM rules WHERE namespace = ?")
+		if err != nil {
+			return err
+		}
+	case subscribe.OpGroupRemove:
+		err := execEach(ctx, tx, "removing group", []any{change.Namespace, change.Group},
+			"DELETE FROM subscriptions WHERE namespace = ? AND grp = ?",
+			"DELETE FROM rules WHERE namespace = ? AND grp = ?")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Removed namespaces and groups are recorded too, so Load removes them from the saved JSON.
+	return recordChange(ctx, tx, change)
+}
+
+func putSubscriber(ctx context.Context, tx *sql.Tx, change *subscribe.Change) error {
+	if change.Sub == nil {
+		return nil
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE subscribers SET admin = ?, ignored = ? WHERE "+subscriberWhere,
+		change.Admin, change.Ignored, change.Namespace, change.Sub.API, change.Sub.ID, change.Sub.Contact)
+	if err != nil {
+		return fmt.Errorf("writing subscriber: %w", err)
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("writing subscriber: %w", err)
+	} else if updated > 0 {
+		return nil
+	}
+
+	return insertSubscriber(ctx, tx, change.Namespace, &subscribe.Subscriber{
+		ID:      change.Sub.ID,
+		API:     change.Sub.API,
+		Contact: change.Sub.Contact,
+		Admin:   change.Admin,
+		Ignored: change.Ignored,
+	})
+}
+
+// removeSubscriber deletes a subscriber and its subscriptions.
+func removeSubscriber(ctx context.Context, tx *sql.Tx, own owner) error {
+	err := execEach(ctx, tx, "removing subscriber", []any{own.namespace, own.api, own.id, own.contact},
+		"DELETE FROM subscribers WHERE "+subscriberWhere)
+	if err != nil {
+		return err
+	}
+
+	return execEach(ctx, tx, "removing subscriber", own.args(),
+		"DELETE FROM subscriptions WHERE "+ownerWhere,
+		"DELETE FROM rules WHERE "+ownerWhere)
+}
+
+func deleteSubscription(ctx context.Context, tx *sql.Tx, own owner, event string) error {
+	return execEach(ctx, tx, "removing subscription", append(own.args(), event),
+		"DELETE FROM subscriptions WHERE "+ownerWhere+" AND event = ?",
+		"DELETE FROM rules WHERE "+ownerWhere+" AND event = ?")
+}
+
+// renameSubscription moves a subscription and its rules to a new event name, replacing any there.
+func renameSubscription(ctx context.Context, tx *sql.Tx, own owner, event, newName string) error {
+	if err := deleteSubscription(ctx, tx, own, newName); err != nil {
+		return err
+	}
+
+	return execEach(ctx, tx, "renaming subscription", append([]any{newName}, append(own.args(), event)...),
+		"UPDATE subscriptions SET event = ? WHERE "+ownerWhere+" AND event = ?",
+		"UPDATE rules SET event = ? WHERE "+ownerWhere+" AND event = ?")
+}
+
+func putRule(ctx context.Context, tx *sql.Tx, own owner, event, kind, rule, value string) error {
+	return execEach(ctx, tx, "writing rule", append(own.args(), event, kind, rule, value),
+		"INSERT INTO rules ("+ownerColumns+", event, kind, rule, value) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT DO UPDATE SET value = excluded.value")
+}
+
+// recordChange saves a change for Load to replay.
+func recordChange(ctx context.Context, tx *sql.Tx, change *subscribe.Change) error {
+	record, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("encoding change: %w", err)
+	}
+
+	return execEach(ctx, tx, "recording change", []any{string(record)}, "INSERT INTO changes (record) VALUES (?)")
+}
+
+// foldChanges replays the recorded changes into the saved JSON, and removes them,
+// once there are foldAt of them.
+func foldChanges(ctx context.Context, tx *sql.Tx, foldAt int) error {
+	var count int
+
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM changes").Scan(&count); err != nil {
+		return fmt.Errorf("counting changes: %w", err)
+	}
+
+	if count < foldAt {
+		return nil
+	}
+
+	db, err := loadState(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	err = execEach(ctx, tx, "folding changes", []any{string(data)},
+		"INSERT INTO state (id, data) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data")
+	if err != nil {
+		return err
+	}
+
+	return execEach(ctx, tx, "folding changes", nil, "DELETE FROM changes")
+}
+
+// execEach runs each query with the same arguments.
+func execEach(ctx context.Context, tx *sql.Tx, what string, args []any, queries ...string) error {
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+	}
+
+	return nil
+}
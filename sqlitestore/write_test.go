@@ -0,0 +1,99 @@
+package sqlitestore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golift.io/subscribe"
+)
+
+func TestWriteUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	store, err := Open(filepath.Join(t.TempDir(), "subscribers.sqlite"))
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	db, err := subscribe.GetStoreDB(store)
+	require.NoError(t, err)
+
+	user := db.CreateSub("user", "pushover", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	user.Events.RuleSetS("motion", "sound", "bell")
+	require.NoError(t, db.CreateSubWithID(7, "admin", "pushover", true, false).Subscribe("motion"))
+
+	// The database only removes subscribers with ImportCSV, so write the change directly.
+	require.NoError(t, store.Write(&subscribe.Change{
+		Op:  subscribe.OpUnsubscribe,
+		Sub: &subscribe.SubscriberRef{Contact: "user", API: "pushover"},
+	}))
+
+	var subscribers, subscriptions, rules int
+
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM subscribers").Scan(&subscribers))
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM subscriptions").Scan(&subscriptions))
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM rules").Scan(&rules))
+	assert.Equal(t, 1, subscribers)
+	assert.Equal(t, 1, subscriptions)
+	assert.Zero(t, rules)
+
+	loaded, err := subscribe.GetStoreDB(store)
+	require.NoError(t, err)
+	require.Len(t, loaded.Subscribers, 1)
+	assert.Equal(t, int64(7), loaded.Subscribers[0].ID)
+}
+
+func TestWriteClosed(t *testing.T) {
+	t.Parallel()
+
+	store, err := Open(filepath.Join(t.TempDir(), "subscribers.sqlite"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	require.Error(t, store.Write(&subscribe.Change{Op: subscribe.OpSeverities, Levels: []string{"info"}}))
+}
+
+func TestWriteFold(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers.sqlite")
+
+	store, err := Open(path)
+	require.NoError(t, err)
+
+	defer store.Close()
+
+	store.foldAt = 3
+
+	db, err := subscribe.GetStoreDB(store)
+	require.NoError(t, err)
+	buildDB(t, db)
+
+	for _, name := range []string{"one", "two", "three", "four", "five"} {
+		db.CreateGroup(name)
+	}
+
+	want, err := db.StateGetJSON()
+	require.NoError(t, err)
+
+	var changes int
+
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM changes").Scan(&changes))
+	assertions.Less(changes, 3, "recorded changes must be folded into the saved state")
+
+	reopened, err := Open(path)
+	require.NoError(t, err)
+
+	defer reopened.Close()
+
+	loaded, err := subscribe.GetStoreDB(reopened)
+	require.NoError(t, err)
+
+	got, err := loaded.StateGetJSON()
+	require.NoError(t, err)
+	assertions.JSONEq(want, got, "folding must keep every change")
+}
@@ -0,0 +1,278 @@
+package subscribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/***********************
+ *    Store Methods    *
+ ***********************/
+
+// Store persists the database in place of a JSON state file. Open a database backed by one
+// with GetStoreDB. StateFileLoad and StateFileSave then call Load and Save, and every change
+// made through the library is handed to Write, like the journal. Changes made directly to
+// struct fields (like Meta and EnableAPIs) are persisted by the next StateFileSave.
+type Store interface {
+	// Load returns the database from the data written by Save, with the changes written by
+	// Write since then applied by Replay. Return nil, or an empty database, if the store is empty.
+	Load(ctx context.Context) (*Subscribe, error)
+	// Save replaces everything in the store with db, a snapshot the store may keep or modify.
+	// Changes made while Save runs are handed to Write after it returns.
+	Save(ctx context.Context, db *Subscribe) error
+	// Write persists a single change. It is called for every change, in order and one at a
+	// time, usually while the database is locked, so it must not call the database's methods.
+	// change may point to the database's data; do not keep it after returning. A failed write
+	// is logged and returned by StoreError, and persisted by the next Save.
+	Write(change *Change) error
+}
+
+// StoreOpener opens, or creates, the Store in a file. See RegisterStore.
+type StoreOpener func(path string) (Store, error)
+
+// storeOpeners are the registered StoreOpeners by file extension.
+var storeOpeners = struct {
+	sync.RWMutex
+	byExt map[string]StoreOpener
+}{byExt: make(map[string]StoreOpener)}
+
+// RegisterStore makes GetDB open files with an extension, like ".db", with a Store instead
+// of as a JSON state file. Store modules call it when imported. Extensions are not case
+// sensitive. A nil opener removes the registration.
+func RegisterStore(extension string, opener StoreOpener) {
+	storeOpeners.Lock()
+	defer storeOpeners.Unlock()
+
+	if opener == nil {
+		delete(storeOpeners.byExt, strings.ToLower(extension))
+	} else {
+		storeOpeners.byExt[strings.ToLower(extension)] = opener
+	}
+}
+
+// storeOpener returns the StoreOpener registered for a file's extension, or nil.
+func storeOpener(path string) StoreOpener {
+	storeOpeners.RLock()
+	defer storeOpeners.RUnlock()
+
+	return storeOpeners.byExt[strings.ToLower(filepath.Ext(path))]
+}
+
+// GetStoreDB returns an interface to manage events, persisted by a Store instead of a state file.
+func GetStoreDB(store Store) (*Subscribe, error) {
+	return getStoreDB(store, "")
+}
+
+// openStoreDB opens a store file and the database in it.
+func openStoreDB(opener StoreOpener, path string) (*Subscribe, error) {
+	store, err := opener(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+
+	sub, err := getStoreDB(store, path)
+	if err != nil {
+		if closer, ok := store.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// getStoreDB returns a database persisted by a store. path is the store's file, for logs and traces.
+func getStoreDB(store Store, path string) (*Subscribe, error) {
+	sub := &Subscribe{
+		stateFile:   path,
+		EnableAPIs:  make([]string, 0),
+		Events:      &Events{Map: make(map[string]*Rules)},
+		Subscribers: make([]*Subscriber, 0),
+		store:       store,
+	}
+
+	err := sub.StateFileLoad()
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Close stops journaling, waits for queued log records, and closes the Store if it has a Close
+// method, like stores GetDB opens. Do not use the database after closing it.
+func (s *Subscribe) Close() error {
+	if err := s.JournalDisable(); err != nil {
+		return err
+	}
+
+	s.flushLogs()
+
+	closer, ok := s.getStore().(io.Closer)
+	if !ok {
+		return nil
+	}
+
+	if err := closer.Close(); err != nil {
+		return fmt.Errorf("closing store: %w", err)
+	}
+
+	return nil
+}
+
+// StoreImport replaces the contents of a store with a state file, and its journal, as written
+// by StateFileSave. Call StateFileLoad on databases already using the store to pick it up.
+func StoreImport(ctx context.Context, store Store, stateFile string) error {
+	loaded, err := new(Subscribe).decodeStateFile(ctx, stateFile)
+	if err != nil {
+		return fmt.Errorf("importing state file: %w", err)
+	}
+
+	if err = store.Save(ctx, loaded.snapshot()); err != nil {
+		return fmt.Errorf("saving store: %w", err)
+	}
+
+	return nil
+}
+
+// Replay applies changes, in order, to a database a Store is loading. It also fills in
+// missing maps and lists, so call it before changing the database, even with no changes.
+func (s *Subscribe) Replay(changes ...*Change) {
+	normalizeLoadedState(s)
+
+	for _, change := range changes {
+		s.apply(change)
+	}
+}
+
+// Replay applies changes, in order, to Events a Store is loading, like a subscriber's.
+// Only changes to subscriptions and their rules apply; others are ignored.
+func (e *Events) Replay(changes ...*Change) {
+	if e.Map == nil {
+		e.Map = make(map[string]*Rules)
+	}
+
+	for _, change := range changes {
+		e.applyChange(change)
+	}
+}
+
+// getStore returns the Store, or nil if the database uses a state file.
+func (s *Subscribe) getStore() Store {
+	root := s.root()
+
+	root.hookMu.RLock()
+	defer root.hookMu.RUnlock()
+
+	return root.store
+}
+
+func (s *Subscribe) storeLoad(ctx context.Context, store Store) error {
+	loaded, err := store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed loading store: %w", err)
+	}
+
+	if loaded == nil {
+		loaded = new(Subscribe)
+	}
+
+	normalizeLoadedState(loaded)
+	s.adoptLoaded(loaded)
+
+	return nil
+}
+
+// storeSave saves a snapshot to the store. Changes made while it saves are written after
+// the save, so it cannot erase the ones that missed the snapshot.
+func (s *Subscribe) storeSave(ctx context.Context, store Store) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	s.storeMu.Lock()
+	s.storeSaving = true
+	s.storeMu.Unlock()
+
+	err := store.Save(ctx, s.snapshot())
+
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	if err == nil {
+		s.storeErr = nil // The snapshot includes the changes that failed to write.
+	}
+
+	for _, change := range s.storePending {
+		s.writeStore(store, change)
+	}
+
+	s.storeSaving, s.storePending = false, nil
+
+	if err != nil {
+		return fmt.Errorf("failed saving store: %w", err)
+	}
+
+	return nil
+}
+
+// storeWrite hands a change to the store, or holds a copy of it while the store saves.
+func (s *Subscribe) storeWrite(store Store, change *Change) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	if !s.storeSaving {
+		s.writeStore(store, change)
+		return
+	}
+
+	// The change may point to the database's data, which can change before the save finishes.
+	held := new(Change)
+
+	buf, err := json.Marshal(change)
+	if err == nil {
+		err = json.Unmarshal(buf, held)
+	}
+
+	if err != nil {
+		s.storeFailed(change, fmt.Errorf("holding change: %w", err))
+		return
+	}
+
+	s.storePending = append(s.storePending, held)
+}
+
+// writeStore writes a change to the store, and records a failure. Call with storeMu held.
+func (s *Subscribe) writeStore(store Store, change *Change) {
+	if err := store.Write(change); err != nil {
+		s.storeFailed(change, err)
+	}
+}
+
+// storeFailed logs a change the store did not write, and keeps the first error for StoreError.
+// Call with storeMu held.
+func (s *Subscribe) storeFailed(change *Change, err error) {
+	s.queueLog(slog.LevelError, "Writing change to store failed", slog.String("op", change.Op), slog.Any("error", err))
+
+	if s.storeErr == nil {
+		s.storeErr = fmt.Errorf("writing change to store: %w", err)
+	}
+}
+
+// StoreError returns the first error writing a change to the Store since the last successful
+// StateFileSave, or nil. Changes that failed to write are only persisted by the next save,
+// so check this periodically and save when it returns an error. Always nil without a Store.
+func (s *Subscribe) StoreError() error {
+	root := s.root()
+
+	root.storeMu.Lock()
+	defer root.storeMu.Unlock()
+
+	return root.storeErr
+}
@@ -0,0 +1,283 @@
+package subscribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errStoreBroken = errors.New("store is broken")
+
+// memStore is a Store that keeps a saved JSON snapshot and the changes written since.
+type memStore struct {
+	mu      sync.Mutex
+	saved   []byte
+	changes [][]byte
+	broken  bool
+}
+
+func (m *memStore) Load(context.Context) (*Subscribe, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.saved == nil && len(m.changes) == 0 {
+		return nil, nil
+	}
+
+	db := new(Subscribe)
+
+	if m.saved != nil {
+		if err := json.Unmarshal(m.saved, db); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make([]*Change, len(m.changes))
+
+	for idx, buf := range m.changes {
+		changes[idx] = new(Change)
+		if err := json.Unmarshal(buf, changes[idx]); err != nil {
+			return nil, err
+		}
+	}
+
+	db.Replay(changes...)
+
+	return db, nil
+}
+
+func (m *memStore) Save(_ context.Context, db *Subscribe) error {
+	buf, err := json.Marshal(db)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saved, m.changes = buf, nil
+
+	return nil
+}
+
+func (m *memStore) Write(change *Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.broken {
+		return errStoreBroken
+	}
+
+	buf, err := json.Marshal(change)
+	if err == nil {
+		m.changes = append(m.changes, buf)
+	}
+
+	return err
+}
+
+// racingStore makes a change between the snapshot and saving it.
+type racingStore struct {
+	*memStore
+	change func()
+}
+
+func (r *racingStore) Save(ctx context.Context, db *Subscribe) error {
+	if r.change != nil {
+		r.change()
+	}
+
+	return r.memStore.Save(ctx, db)
+}
+
+func TestStoreSaveRace(t *testing.T) {
+	t.Parallel()
+
+	store := &racingStore{memStore: &memStore{}}
+
+	sub, err := GetStoreDB(store)
+	require.NoError(t, err)
+	require.NoError(t, sub.CreateSub("early", "api", false, false).Subscribe("motion"))
+
+	store.change = func() {
+		assert.NoError(t, sub.CreateSub("late", "api", false, false).Subscribe("door"))
+	}
+
+	require.NoError(t, sub.StateFileSave())
+	assert.Len(t, store.changes, 2, "changes made during the save must be written after it")
+
+	loaded, err := GetStoreDB(store.memStore)
+	require.NoError(t, err)
+
+	late, err := loaded.GetSubscriber("late", "api")
+	require.NoError(t, err, "the save must not erase a change that missed its snapshot")
+	assert.Equal(t, []string{"door"}, late.Events.Names())
+	assert.Len(t, loaded.Subscribers, 2)
+}
+
+func TestGetStoreDB(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	store := &memStore{}
+
+	sub, err := GetStoreDB(store)
+	require.NoError(t, err)
+	assertions.Empty(sub.Subscribers)
+
+	user := sub.CreateSub("user", "pushover", false, false)
+	require.NoError(t, user.Subscribe("motion"))
+	user.Events.RuleSetI("motion", "count", 3)
+	require.NoError(t, sub.Namespace("tenant").CreateSub("other", "slack", true, false).Subscribe("door"))
+
+	want, err := sub.StateGetJSON()
+	require.NoError(t, err)
+	assertions.NotEmpty(store.changes, "every change must be written to the store")
+
+	loaded, err := GetStoreDB(store)
+	require.NoError(t, err)
+
+	got, err := loaded.StateGetJSON()
+	require.NoError(t, err)
+	assertions.JSONEq(want, got, "loading must replay the changes written to the store")
+
+	require.NoError(t, sub.StateFileSave())
+	assertions.NotNil(store.saved)
+	assertions.Empty(store.changes)
+
+	require.NoError(t, loaded.StateFileLoad())
+
+	got, err = loaded.StateGetJSON()
+	require.NoError(t, err)
+	assertions.JSONEq(want, got, "loading must restore the saved database")
+
+	require.ErrorIs(t, sub.StateFileRelocate(filepath.Join(t.TempDir(), "moved.json")), ErrStoreInUse)
+	require.ErrorIs(t, sub.Namespace("tenant").JournalEnable(0), ErrStoreInUse)
+}
+
+func TestStoreWriteError(t *testing.T) {
+	t.Parallel()
+
+	store := &memStore{broken: true}
+
+	sub, err := GetStoreDB(store)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	sub.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	sub.CreateSub("user", "pushover", false, false)
+	sub.flushLogs()
+	assert.Contains(t, buf.String(),
+		`level=ERROR msg="Writing change to store failed" op=subscriber error="store is broken"`)
+	require.ErrorIs(t, sub.StoreError(), errStoreBroken, "a failed write must be reported")
+	require.ErrorIs(t, sub.Namespace("tenant").StoreError(), errStoreBroken, "namespaces share the root's store")
+
+	require.NoError(t, sub.StateFileSave(), "saving must persist the change the store failed to write")
+	require.NoError(t, sub.StoreError(), "a successful save must clear the write error")
+
+	loaded, err := GetStoreDB(store)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Subscribers, 1)
+}
+
+func TestStoreImport(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.JournalEnable(0))
+	require.NoError(t, sub.CreateSub("user", "pushover", false, false).Subscribe("motion"))
+	require.NoError(t, sub.JournalDisable(), "the journaled subscription must be imported too")
+
+	want, err := sub.StateGetJSON()
+	require.NoError(t, err)
+
+	store := &memStore{}
+	require.NoError(t, StoreImport(t.Context(), store, path))
+
+	loaded, err := GetStoreDB(store)
+	require.NoError(t, err)
+
+	got, err := loaded.StateGetJSON()
+	require.NoError(t, err)
+	assertions.JSONEq(want, got)
+
+	require.Error(t, StoreImport(t.Context(), store, filepath.Join(t.TempDir(), "missing.json")))
+}
+
+// closingStore is a memStore that records being closed.
+type closingStore struct {
+	*memStore
+	closed bool
+}
+
+func (c *closingStore) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRegisterStore(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	store := &closingStore{memStore: &memStore{}}
+	path := filepath.Join(t.TempDir(), "subscribers.MemTest")
+
+	RegisterStore(".memtest", func(opened string) (Store, error) {
+		if opened != path {
+			return nil, errStoreBroken
+		}
+
+		return store, nil
+	})
+
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+	require.NoError(t, sub.CreateSub("user", "pushover", false, false).Subscribe("motion"))
+	require.NoError(t, sub.Close())
+	assertions.True(store.closed)
+	assertions.NoFileExists(path, "the store must be used instead of a state file")
+
+	loaded, err := GetDB(path)
+	require.NoError(t, err)
+	assertions.Len(loaded.Subscribers, 1)
+
+	_, err = GetDB(filepath.Join(t.TempDir(), "other.memtest"))
+	require.ErrorIs(t, err, errStoreBroken)
+
+	RegisterStore(".memtest", nil)
+
+	loaded, err = GetDB(path)
+	require.NoError(t, err)
+	assertions.Empty(loaded.Subscribers)
+	assertions.FileExists(path, "without a store the file is a state file")
+	require.NoError(t, loaded.Close())
+}
+
+func TestEventsReplay(t *testing.T) {
+	t.Parallel()
+
+	events := new(Events)
+	events.Replay(
+		&Change{Op: OpNew, Event: "motion", Rules: &Rules{S: map[string]string{"room": "den"}}},
+		&Change{Op: OpRuleSet, Event: "motion", Kind: "I", Rule: "count", Rules: &Rules{I: map[string]int{"count": 3}}},
+		&Change{Op: OpRename, Event: "motion", To: "movement"},
+		&Change{Op: OpSeverities, Levels: []string{"info"}},
+	)
+
+	count, _ := events.RuleGetI("movement", "count")
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []string{"movement"}, events.Names())
+}
@@ -0,0 +1,369 @@
+// Package storetest checks that a database file persists everything, whether GetDB opens
+// it as a JSON state file or with a registered Store. Store modules run it in their tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.RunStore(t, ".db", func(path string) (subscribe.Store, error) { return Open(path) })
+//	}
+package storetest
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golift.io/subscribe"
+)
+
+// Run tests databases that GetDB opens from files with an extension, like ".json".
+func Run(t *testing.T, extension string) {
+	t.Helper()
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+		testEmpty(t, filepath.Join(t.TempDir(), "subscribers"+extension))
+	})
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		t.Parallel()
+		testSaveAndLoad(t, filepath.Join(t.TempDir(), "subscribers"+extension))
+	})
+	t.Run("Update", func(t *testing.T) {
+		t.Parallel()
+		testUpdate(t, filepath.Join(t.TempDir(), "subscribers"+extension))
+	})
+	t.Run("Reload", func(t *testing.T) {
+		t.Parallel()
+		testReload(t, filepath.Join(t.TempDir(), "subscribers"+extension))
+	})
+	t.Run("Unsaved", func(t *testing.T) {
+		t.Parallel()
+		testUnsaved(t, filepath.Join(t.TempDir(), "subscribers"+extension))
+	})
+}
+
+// RunStore tests a Store module: everything Run tests, plus databases opened with GetStoreDB
+// and state files imported with StoreImport. opener opens the module's Store, like the
+// StoreOpener it registers for extension.
+func RunStore(t *testing.T, extension string, opener subscribe.StoreOpener) {
+	t.Helper()
+
+	Run(t, extension)
+
+	t.Run("StoreDB", func(t *testing.T) {
+		t.Parallel()
+		testStoreDB(t, filepath.Join(t.TempDir(), "subscribers"+extension), opener)
+	})
+	t.Run("Import", func(t *testing.T) {
+		t.Parallel()
+		testImport(t, t.TempDir(), extension, opener)
+	})
+}
+
+func testEmpty(t *testing.T, path string) {
+	t.Helper()
+
+	db := open(t, path)
+	want := stateJSON(t, db)
+	closeDB(t, db)
+
+	if len(db.Subscribers) != 0 || len(db.Events.Map) != 0 {
+		t.Errorf("a new database must be empty, got: %s", want)
+	}
+
+	db = open(t, path)
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("reopening an empty database must keep it empty:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func testSaveAndLoad(t *testing.T, path string) {
+	t.Helper()
+
+	db := open(t, path)
+	populate(t, db)
+	want := save(t, db)
+
+	db = open(t, path)
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("reopening must restore the database:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func testUpdate(t *testing.T, path string) {
+	t.Helper()
+
+	db := open(t, path)
+	populate(t, db)
+	save(t, db)
+
+	db = open(t, path)
+	update(t, db)
+	want := save(t, db)
+
+	db = open(t, path)
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("reopening must restore the updated database:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func testReload(t *testing.T, path string) {
+	t.Helper()
+
+	db := open(t, path)
+	defer closeDB(t, db)
+
+	populate(t, db)
+
+	if err := db.StateFileSave(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	want := stateJSON(t, db)
+
+	if err := db.StateFileLoad(); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("loading must restore the saved database:\nwant: %s\n got: %s", want, got)
+	}
+
+	// Changes after loading must still be persisted, so the hooks must be in place.
+	update(t, db)
+	want = save(t, db)
+
+	db = open(t, path)
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("changes made after loading must be saved:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func testUnsaved(t *testing.T, path string) {
+	t.Helper()
+
+	db := open(t, path)
+	populate(t, db)
+	save(t, db)
+
+	// A state file keeps changes made since the last save in its journal; a store keeps every change.
+	db = open(t, path)
+	if err := db.JournalEnable(0); err != nil && !errors.Is(err, subscribe.ErrStoreInUse) {
+		t.Fatalf("journaling: %v", err)
+	}
+
+	update(t, db)
+	want := stateJSON(t, db)
+	closeDB(t, db)
+
+	db = open(t, path)
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("changes must be kept without saving:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func testStoreDB(t *testing.T, path string, opener subscribe.StoreOpener) {
+	t.Helper()
+
+	db := openStore(t, path, opener)
+	populate(t, db)
+
+	if err := db.StateFileSave(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	update(t, db)
+	want := stateJSON(t, db)
+	closeDB(t, db)
+
+	db = openStore(t, path, opener)
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("loading must restore every change:\nwant: %s\n got: %s", want, got)
+	}
+
+	if err := db.StateFileSave(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	// Fields changed directly are only persisted by saving.
+	db.EnableAPIs = append(db.EnableAPIs, "unsaved")
+
+	if err := db.StateFileLoad(); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("loading must restore the saved database:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func testImport(t *testing.T, dir, extension string, opener subscribe.StoreOpener) {
+	t.Helper()
+
+	stateFile := filepath.Join(dir, "subscribers.json")
+	source := open(t, stateFile)
+	populate(t, source)
+	want := save(t, source)
+
+	store, err := opener(filepath.Join(dir, "subscribers"+extension))
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+
+	if err = subscribe.StoreImport(t.Context(), store, stateFile); err != nil {
+		t.Fatalf("importing: %v", err)
+	}
+
+	if err = subscribe.StoreImport(t.Context(), store, filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("importing a missing state file must fail")
+	}
+
+	db, err := subscribe.GetStoreDB(store)
+	if err != nil {
+		t.Fatalf("opening imported store: %v", err)
+	}
+
+	defer closeDB(t, db)
+
+	if got := stateJSON(t, db); got != want {
+		t.Errorf("the imported database must match the state file:\nwant: %s\n got: %s", want, got)
+	}
+}
+
+// populate fills a database with a bit of everything.
+func populate(t *testing.T, db *subscribe.Subscribe) {
+	t.Helper()
+
+	check := func(err error) {
+		t.Helper()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.EnableAPIs = append(db.EnableAPIs, "pushover", "slack")
+	db.SetSeverities("info", "warning", "critical")
+	check(db.DefineEvent(&subscribe.EventDefinition{Name: "motion", Description: "Motion detected", Severity: "warning"}))
+	check(db.Events.New("door", &subscribe.Rules{S: map[string]string{"room": "hall"}}))
+
+	user := db.CreateSub("user", "pushover", false, false)
+	user.Meta = map[string]any{"name": "User"}
+	check(user.Subscribe("motion"))
+	check(user.Subscribe("door"))
+	check(user.Events.Pause("door", time.Hour))
+	user.Events.RuleSetD("motion", "delay", time.Minute)
+	user.Events.RuleSetI("motion", "count", 3)
+	user.Events.RuleSetS("motion", "sound", "bell")
+	user.Events.RuleSetT("motion", "since", time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC))
+
+	admin := db.CreateSubWithID(7, "admin", "slack", true, false)
+	check(admin.Subscribe("motion"))
+	db.CreateSub("ignored", "pushover", false, true)
+
+	group := db.CreateGroup("ops")
+	group.AddMember(user)
+	group.AddMember(admin)
+	check(group.Events.New("door", nil))
+	group.Events.RuleSetS("door", "sound", "horn")
+
+	tenant := db.Namespace("tenant")
+	check(tenant.Events.New("alarm", nil))
+	check(tenant.CreateSub("other", "slack", false, false).Subscribe("alarm"))
+}
+
+// update changes, renames and removes some of what populate added.
+func update(t *testing.T, db *subscribe.Subscribe) {
+	t.Helper()
+
+	user, err := db.GetSubscriber("user", "pushover")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user.Events.RuleDelI("motion", "count")
+	user.Events.Remove("door")
+	db.CreateSubWithID(7, "admin", "slack", false, true)
+
+	if err = db.EventRename("motion", "movement"); err != nil {
+		t.Fatal(err)
+	}
+
+	db.GroupRemove("ops")
+	db.NamespaceRemove("tenant")
+
+	if err = db.Namespace("tenant").CreateSub("new", "slack", false, false).Subscribe("alarm"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func open(t *testing.T, path string) *subscribe.Subscribe {
+	t.Helper()
+
+	db, err := subscribe.GetDB(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+
+	return db
+}
+
+func openStore(t *testing.T, path string, opener subscribe.StoreOpener) *subscribe.Subscribe {
+	t.Helper()
+
+	store, err := opener(path)
+	if err != nil {
+		t.Fatalf("opening store %s: %v", path, err)
+	}
+
+	db, err := subscribe.GetStoreDB(store)
+	if err != nil {
+		t.Fatalf("loading store %s: %v", path, err)
+	}
+
+	return db
+}
+
+// save saves and closes a database, and returns its contents.
+func save(t *testing.T, db *subscribe.Subscribe) string {
+	t.Helper()
+
+	if err := db.StateFileSave(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	state := stateJSON(t, db)
+	closeDB(t, db)
+
+	return state
+}
+
+func closeDB(t *testing.T, db *subscribe.Subscribe) {
+	t.Helper()
+
+	if err := db.Close(); err != nil {
+		t.Errorf("closing: %v", err)
+	}
+}
+
+func stateJSON(t *testing.T, db *subscribe.Subscribe) string {
+	t.Helper()
+
+	state, err := db.StateGetJSON()
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	return state
+}
@@ -13,12 +13,13 @@ func (s *Subscribe) CreateSub(contact, api string, admin, ignore bool) *Subscrib
 		if contact == s.Subscribers[i].Contact && api == s.Subscribers[i].API {
 			s.Subscribers[i].Admin = admin
 			s.Subscribers[i].Ignored = ignore
+			s.emitSubscriber(s.Subscribers[i])
 			// Already exists, return it.
 			return s.Subscribers[i]
 		}
 	}
 
-	s.Subscribers = append(s.Subscribers, &Subscriber{
+	sub := &Subscriber{
 		Contact: contact,
 		API:     api,
 		Admin:   admin,
@@ -26,9 +27,12 @@ func (s *Subscribe) CreateSub(contact, api string, admin, ignore bool) *Subscrib
 		Events: &Events{
 			Map: make(map[string]*Rules),
 		},
-	})
+	}
+	s.Subscribers = append(s.Subscribers, sub)
+	s.emitSubscriber(sub)
+	s.attachSubscriberHook(sub)
 
-	return s.Subscribers[len(s.Subscribers)-1]
+	return sub
 }
 
 // CreateSubWithID creates or updates a subscriber with a given ID.
@@ -44,6 +48,7 @@ func (s *Subscribe) CreateSubWithID(subID int64, contact, api string, admin, ign
 		if subID == s.Subscribers[i].ID && api == s.Subscribers[i].API {
 			s.Subscribers[i].Admin = admin
 			s.Subscribers[i].Ignored = ignore
+			s.emitSubscriber(s.Subscribers[i])
 			// Already exists, return it.
 			return s.Subscribers[i]
 		}
@@ -60,10 +65,54 @@ func (s *Subscribe) CreateSubWithID(subID int64, contact, api string, admin, ign
 		},
 	}
 	s.Subscribers = append(s.Subscribers, sub)
+	s.emitSubscriber(sub)
+	s.attachSubscriberHook(sub)
 
 	return sub
 }
 
+// emitSubscriber records the creation or update of a subscriber.
+func (s *Subscribe) emitSubscriber(sub *Subscriber) {
+	s.emit(&Change{Op: OpSubscriber, Sub: sub.ref(), Admin: sub.Admin, Ignored: sub.Ignored})
+}
+
+// removeSubscribers deletes every subscriber the filter returns true for.
+func (s *Subscribe) removeSubscribers(remove func(*Subscriber) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := make([]*Subscriber, 0, len(s.Subscribers))
+
+	for _, sub := range s.Subscribers {
+		if !remove(sub) {
+			kept = append(kept, sub)
+			continue
+		}
+
+		s.emit(&Change{Op: OpUnsubscribe, Sub: sub.ref()})
+	}
+
+	s.Subscribers = kept
+}
+
+// ref returns the identity of a subscriber.
+func (s *Subscriber) ref() *SubscriberRef {
+	return &SubscriberRef{ID: s.ID, Contact: s.Contact, API: s.API}
+}
+
+// matches returns true if the subscriber has the identity provided.
+func (s *Subscriber) matches(ref *SubscriberRef) bool {
+	if ref == nil || s.API != ref.API {
+		return false
+	}
+
+	if ref.ID != 0 {
+		return s.ID == ref.ID
+	}
+
+	return s.Contact == ref.Contact
+}
+
 /* Convenience methods to access specific types of subscribers. */
 
 // GetSubscriber gets a subscriber based on their contact info.
@@ -103,6 +152,10 @@ func (s *Subscribe) GetAdmins() []*Subscriber {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return s.getAdminsLocked()
+}
+
+func (s *Subscribe) getAdminsLocked() []*Subscriber {
 	subs := make([]*Subscriber, 0, len(s.Subscribers))
 
 	for idx := range s.Subscribers {
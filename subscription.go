@@ -1,8 +1,8 @@
 package subscribe
 
 import (
+	"context"
 	"strings"
-	"time"
 )
 
 /****************************
@@ -10,29 +10,122 @@ import (
  ****************************/
 
 // Subscribe adds an event subscription to a subscriber.
+// The subscription starts with the event definition's default rules, if any.
 // Returns an error only if the event subscription already exists.
 func (s *Subscriber) Subscribe(event string) error {
-	return s.Events.New(event, &Rules{Pause: time.Now()})
+	return s.Events.subscribe(event)
 }
 
 // GetSubscribers returns a list of valid event subscribers.
 // This is the main method that should be triggered when an event occurs.
 // Call this method when your event fires, collect the subscribers and send
 // them notifications in your app. Subscribers can be people. Or functions.
+// Members of groups subscribed to the event are included once each, after
+// the direct subscribers. An alias is replaced by the event it points to.
+// Subscribers with a digest subscription are not returned; see Dispatch and FlushDigests.
+// The occurrence has the severity of the event's definition, if any, and subscriptions with
+// a minimum severity above it are skipped. Use GetOccurrenceSubscribers to provide another
+// severity; GetSubscribers keeps taking only an event name so existing callers still work.
+// Subscriptions with a filter are skipped, because there are no attributes to check them
+// against; use GetSubscribersFor to pass attributes.
 func (s *Subscribe) GetSubscribers(eventName string) []*Subscriber {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.GetSubscribersContext(context.Background(), eventName)
+}
+
+// GetSubscribersContext works like GetSubscribers, in a span of ctx's trace. See SetTracer.
+func (s *Subscribe) GetSubscribersContext(ctx context.Context, eventName string) []*Subscriber {
+	subscribers, _ := s.traceSubscribers(ctx, "GetSubscribers", eventName, func() ([]*Subscriber, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		occurrence := s.resolveOccurrenceLocked(&Occurrence{Event: eventName})
 
+		accept := unfilteredAccept(occurrence.Event)
+		// A definition's severity missing from the Severities list is ignored.
+		if withSeverity, err := s.severityAcceptLocked(occurrence, accept); err == nil {
+			accept = withSeverity
+		}
+
+		return s.subscribersLocked(occurrence, accept, lookupQuery), nil
+	})
+
+	return subscribers
+}
+
+// lookup says what a subscriber lookup records, besides returning the subscribers.
+type lookup int
+
+const (
+	// lookupQuiet records nothing. Used for lookups the library makes itself.
+	lookupQuiet lookup = iota
+	// lookupQuery records the lookup in the audit log and metrics, if enabled.
+	lookupQuery
+	// lookupDispatch also adds the occurrence to digests.
+	lookupDispatch
+)
+
+// subscribersLocked returns the direct subscribers, then group members, for an occurrence.
+// If accept is not nil, it must return true for the subscriber's (or group's) Events.
+// Direct subscribers with a digest subscription are not returned, and get the occurrence added
+// to their digest by lookupDispatch. Call with mu held.
+func (s *Subscribe) subscribersLocked(occurrence *Occurrence, accept func(*Events) bool, kind lookup) []*Subscriber {
+	eventName := occurrence.Event
 	subscribers := make([]*Subscriber, 0, len(s.Subscribers))
+	audit := kind != lookupQuiet && s.auditing()
+	excluded := []*Exclusion{}
+
 	for _, sub := range s.Subscribers {
-		if !sub.Ignored && s.checkAPILocked(sub.API) && !sub.Events.IsPaused(eventName) {
+		reason := s.exclusionLocked(sub, occurrence, accept, kind == lookupDispatch)
+		if reason == "" {
 			subscribers = append(subscribers, sub)
+		} else if audit {
+			excluded = append(excluded, &Exclusion{Subscriber: *sub.ref(), Reason: reason})
 		}
 	}
 
+	if len(s.Groups) > 0 {
+		seen := make(map[*Subscriber]bool, len(subscribers))
+		for _, sub := range subscribers {
+			seen[sub] = true
+		}
+
+		subscribers = append(subscribers, s.groupSubscribersLocked(eventName, seen, accept)...)
+	}
+
+	if audit {
+		s.recordAudit(occurrence, subscribers, excluded)
+	}
+
+	if kind != lookupQuiet {
+		s.countCall(len(subscribers))
+	}
+
 	return subscribers
 }
 
+// exclusionLocked returns why a subscriber's own subscription does not get an occurrence,
+// or an empty reason if it does. Call with mu held.
+func (s *Subscribe) exclusionLocked(
+	sub *Subscriber, occurrence *Occurrence, accept func(*Events) bool, collect bool,
+) Reason {
+	switch {
+	case sub.Ignored:
+		return ReasonIgnored
+	case !s.checkAPILocked(sub.API):
+		return ReasonAPIDisabled
+	case sub.Events.Match(occurrence.Event) == "":
+		return ReasonNotSubscribed
+	case sub.Events.IsPaused(occurrence.Event):
+		return ReasonPaused
+	case accept != nil && !accept(sub.Events):
+		return ReasonFiltered
+	case s.digestLocked(sub, occurrence, collect):
+		return ReasonDigest
+	default:
+		return ""
+	}
+}
+
 // checkAPI just looks for a string in a slice of strings with a twist.
 func (s *Subscribe) checkAPI(api string) bool {
 	s.mu.RLock()
@@ -42,27 +135,40 @@ func (s *Subscribe) checkAPI(api string) bool {
 }
 
 func (s *Subscribe) checkAPILocked(api string) bool {
+	_, ok := s.matchAPILocked(api)
+
+	return ok
+}
+
+// matchAPILocked returns the EnableAPIs entry that allows an API, and true if one does.
+// An empty EnableAPIs allows every API with an empty entry.
+func (s *Subscribe) matchAPILocked(api string) (string, bool) {
 	if len(s.EnableAPIs) < 1 {
-		return true
+		return "", true
 	}
 
 	for _, a := range s.EnableAPIs {
 		if a == api || strings.HasPrefix(api, a) || a == "all" || a == "any" {
-			return true
+			return a, true
 		}
 	}
 
-	return false
+	return "", false
 }
 
-// EventRemove obliterates an event and all subscriptions for it.
+// EventRemove obliterates an event, its definition and all subscriptions for it.
 func (s *Subscribe) EventRemove(event string) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	s.Events.Remove(event)
+	s.undefineLocked(event)
 
 	for _, sub := range s.Subscribers {
 		sub.Events.Remove(event)
 	}
+
+	for _, group := range s.Groups {
+		group.Events.Remove(event)
+	}
 }
@@ -0,0 +1,88 @@
+package subscribe
+
+import (
+	"context"
+)
+
+/*************************
+ *    Tracing Methods    *
+ *************************/
+
+// Tracer starts spans around database operations. It is small on purpose, so this module
+// does not depend on a tracing library. The golift.io/subscribe/otelsubscribe module
+// provides an OpenTelemetry Tracer.
+type Tracer interface {
+	// Start begins a span, a child of any span in ctx, and returns a context holding it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation started by a Tracer.
+type Span interface {
+	// SetAttribute records a string, int or bool value on the span.
+	SetAttribute(key string, value any)
+	// End finishes the span. err is the error the operation returned, if any.
+	End(err error)
+}
+
+// Span attribute keys.
+const (
+	SpanAttrNamespace   = "subscribe.namespace"
+	SpanAttrEvent       = "subscribe.event"
+	SpanAttrSubscribers = "subscribe.subscribers"
+	SpanAttrPath        = "subscribe.path"
+)
+
+// nopSpan is used when there is no Tracer.
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, any) {}
+func (nopSpan) End(error)                {}
+
+// SetTracer traces the context variants of GetSubscribers, GetSubscribersFor,
+// GetOccurrenceSubscribers, StateFileLoad and StateFileSave. The variants without
+// a context are traced too, as root spans. Span names are the method names without
+// Context, prefixed with "subscribe.", like "subscribe.GetSubscribers". A nil tracer,
+// the default, disables tracing. The tracer is shared by every namespace.
+func (s *Subscribe) SetTracer(tracer Tracer) {
+	root := s.root()
+
+	root.hookMu.Lock()
+	defer root.hookMu.Unlock()
+
+	root.tracer = tracer
+}
+
+// startSpan starts a span with the Tracer, if there is one.
+func (s *Subscribe) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	root := s.root()
+
+	root.hookMu.RLock()
+	tracer := root.tracer
+	root.hookMu.RUnlock()
+
+	if tracer == nil {
+		return ctx, nopSpan{}
+	}
+
+	ctx, span := tracer.Start(ctx, "subscribe."+name)
+	if s.namespace != "" {
+		span.SetAttribute(SpanAttrNamespace, s.namespace)
+	}
+
+	return ctx, span
+}
+
+// traceSubscribers runs a subscriber lookup in a span.
+func (s *Subscribe) traceSubscribers(
+	ctx context.Context, name, event string, get func() ([]*Subscriber, error),
+) ([]*Subscriber, error) {
+	_, span := s.startSpan(ctx, name)
+	span.SetAttribute(SpanAttrEvent, event)
+
+	subscribers, err := get()
+
+	span.SetAttribute(SpanAttrSubscribers, len(subscribers))
+	span.End(err)
+
+	return subscribers, err
+}
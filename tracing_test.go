@@ -0,0 +1,115 @@
+package subscribe
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+// spanRecorder is an in-memory Tracer.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type spanKey struct{}
+
+func (r *spanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: make(map[string]any)}
+	span.parent, _ = ctx.Value(spanKey{}).(string)
+
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, name), &recorderSpan{recorder: r, span: span}
+}
+
+type recorderSpan struct {
+	recorder *spanRecorder
+	span     *recordedSpan
+}
+
+func (s *recorderSpan) SetAttribute(key string, value any) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	s.span.attrs[key] = value
+}
+
+func (s *recorderSpan) End(err error) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	s.span.err, s.span.ended = err, true
+}
+
+func TestSetTracer(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	path := filepath.Join(t.TempDir(), "subscribers.json")
+
+	sub, err := GetDB(path)
+	require.NoError(t, err)
+
+	recorder := &spanRecorder{}
+	sub.SetTracer(recorder)
+
+	require.NoError(t, sub.CreateSub("user", "pushover", false, false).Subscribe("motion"))
+
+	ctx := context.WithValue(t.Context(), spanKey{}, "request")
+	assertions.Len(sub.GetSubscribersContext(ctx, "motion"), 1)
+	assertions.Empty(sub.Namespace("tenant").GetSubscribersForContext(ctx, "door", nil))
+	require.NoError(t, sub.StateFileSaveContext(ctx))
+	require.NoError(t, sub.StateFileLoadContext(ctx))
+
+	sub.SetSeverities("info")
+
+	_, err = sub.GetOccurrenceSubscribersContext(ctx, &Occurrence{Event: "motion", Severity: "bogus"})
+	require.ErrorIs(t, err, ErrUnknownSeverity)
+
+	sub.GetSubscribers("motion")
+
+	require.Len(t, recorder.spans, 6)
+
+	for _, span := range recorder.spans {
+		assertions.True(span.ended, span.name)
+	}
+
+	assertions.Equal("subscribe.GetSubscribers", recorder.spans[0].name)
+	assertions.Equal("request", recorder.spans[0].parent)
+	assertions.Equal(map[string]any{SpanAttrEvent: "motion", SpanAttrSubscribers: 1}, recorder.spans[0].attrs)
+
+	assertions.Equal("subscribe.GetSubscribersFor", recorder.spans[1].name)
+	assertions.Equal(map[string]any{
+		SpanAttrNamespace: "tenant", SpanAttrEvent: "door", SpanAttrSubscribers: 0,
+	}, recorder.spans[1].attrs)
+
+	assertions.Equal("subscribe.StateFileSave", recorder.spans[2].name)
+	assertions.Equal(path, recorder.spans[2].attrs[SpanAttrPath])
+	require.NoError(t, recorder.spans[2].err)
+	assertions.Equal("subscribe.StateFileLoad", recorder.spans[3].name)
+
+	assertions.Equal("subscribe.GetOccurrenceSubscribers", recorder.spans[4].name)
+	require.ErrorIs(t, recorder.spans[4].err, ErrUnknownSeverity)
+
+	assertions.Equal("subscribe.GetSubscribers", recorder.spans[5].name)
+	assertions.Empty(recorder.spans[5].parent, "calls without a context are root spans")
+
+	sub.SetTracer(nil)
+	sub.GetSubscribers("motion")
+	assertions.Len(recorder.spans, 6, "a nil tracer disables tracing")
+}
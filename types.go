@@ -1,8 +1,11 @@
 package subscribe
 
 import (
+	"crypto/sha256"
 	"errors"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,6 +16,34 @@ var (
 	ErrEventNotFound = errors.New("event not found")
 	// ErrEventExists is returned when a new event with an existing name is created.
 	ErrEventExists = errors.New("event already exists")
+	// ErrInvalidDefinition is returned when an event definition is nil or has no name.
+	ErrInvalidDefinition = errors.New("invalid event definition")
+	// ErrGroupNotFound is returned when a requested subscriber group does not exist.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrNoStateFile is returned when a feature requires a state file and none is configured.
+	ErrNoStateFile = errors.New("state file path is not configured")
+	// ErrStateFileChanged is returned when the state file was modified by someone else since it was loaded.
+	ErrStateFileChanged = errors.New("state file changed since it was loaded")
+	// ErrCSVHeader is returned when a CSV file has a missing or incomplete header row.
+	ErrCSVHeader = errors.New("invalid csv header")
+	// ErrCSVMissingValue is returned for a CSV row missing a required value.
+	ErrCSVMissingValue = errors.New("missing required value")
+	// ErrFileLockUnsupported is returned by state file methods on platforms without file locks, like Plan 9.
+	ErrFileLockUnsupported = errors.New("file locks are not supported on this platform")
+	// ErrStoreInUse is returned by state file and journal methods that do not work with a Store.
+	ErrStoreInUse = errors.New("database is backed by a store")
+	// ErrJournalCorrupt is returned when a journal record, other than the last one, cannot be decoded.
+	ErrJournalCorrupt = errors.New("journal record is corrupt")
+	// ErrUnknownSeverity is returned for a severity level missing from the severity levels list.
+	ErrUnknownSeverity = errors.New("unknown severity level")
+	// ErrInvalidFilter is returned when a subscription filter expression cannot be parsed.
+	ErrInvalidFilter = errors.New("invalid filter expression")
+	// ErrNoEscalation is returned when escalating an event without an escalation policy.
+	ErrNoEscalation = errors.New("event has no escalation policy")
+	// ErrOccurrenceNotFound is returned when an occurrence ID is not being tracked.
+	ErrOccurrenceNotFound = errors.New("occurrence not found")
+	// ErrNotNotified is returned when a subscriber acknowledges an occurrence they were not notified of.
+	ErrNotNotified = errors.New("subscriber was not notified of this occurrence")
 )
 
 // Rules contains the pause time and rules for a subscriber's event subscription.
@@ -25,6 +56,22 @@ type Rules struct {
 	T     map[string]time.Time     `json:"times"`
 }
 
+// EventDefinition describes a global event for display in a catalog of subscribable events.
+// Defaults are copied into every new subscription made with Subscriber.Subscribe.
+type EventDefinition struct {
+	// Name is the event name.
+	Name string `json:"name"`
+	// Description, Category, Owner and Severity are unused by the library and available for consumers.
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	// Defaults are the rules new subscriptions start with. The pause time is not used.
+	Defaults *Rules `json:"defaults,omitempty"`
+	// Escalation decides who is notified, and when, for occurrences passed to Escalate.
+	Escalation *EscalationPolicy `json:"escalation,omitempty"`
+}
+
 // Subscriber describes the contact info and subscriptions for a person.
 type Subscriber struct {
 	// ID is optional. If it provided, this is used as the _match_.
@@ -44,6 +91,20 @@ type Subscriber struct {
 	Ignored bool `json:"ignored"`
 }
 
+// Group is a named set of subscribers that can be subscribed to events as one.
+type Group struct {
+	// Name is the unique name of the group.
+	Name string `json:"name"`
+	// Members identifies the subscribers in the group. Use the provided methods to interact with it.
+	Members []*SubscriberRef `json:"members"`
+	// Events is a list of events the group is subscribed to, including a cooldown/pause time.
+	Events *Events `json:"events"`
+	// mu protects Members.
+	mu sync.RWMutex
+	// owner is the database the group belongs to.
+	owner *Subscribe
+}
+
 // Events represents the map of tracked global Events.
 // This is an arbitrary list that can be used to filter
 // notifications in a consuming application.
@@ -52,6 +113,16 @@ type Events struct {
 	Map map[string]*Rules `json:"eventsMap"`
 	// sync.mu locks and unlocks the Events map
 	mu sync.RWMutex
+	// notify receives every mutation made through the Events methods. Called with mu held.
+	notify func(*Change)
+	// defaults returns the rules a new subscription starts with, or nil. Called without mu held.
+	defaults func(event string) *Rules
+	// fold makes event names case-insensitive. Set from the owning Subscribe.
+	fold bool
+	// patterns caches the names in Map that are patterns. nil when they must be listed again.
+	patterns atomic.Pointer[[]string]
+	// filters caches parsed filter expressions by their text. See SetFilter.
+	filters sync.Map
 }
 
 // Subscribe is the data needed to initialize this module.
@@ -68,4 +139,102 @@ type Subscribe struct {
 	Events *Events `json:"events"`
 	// Subscribers is a list of all Subscribers.
 	Subscribers []*Subscriber `json:"subscribers"`
+	// Groups are named sets of subscribers. Use the included methods to interact with it.
+	Groups map[string]*Group `json:"groups,omitempty"`
+	// Definitions describes the global events. Use the included methods to interact with it.
+	Definitions map[string]*EventDefinition `json:"definitions,omitempty"`
+	// Severities lists the valid severity levels, lowest first. Use SetSeverities to change it.
+	Severities []string `json:"severities,omitempty"`
+	// Digests holds occurrences waiting to be returned by FlushDigests.
+	Digests []*Digest `json:"digests,omitempty"`
+	// Escalations holds the occurrences being escalated. Use Escalate, Acknowledge and Tick.
+	Escalations []*Escalation `json:"escalations,omitempty"`
+	// Occurrences records dispatched and escalated occurrences, and who responded to them.
+	Occurrences []*OccurrenceRecord `json:"occurrences,omitempty"`
+	// Aliases maps old event names to the events they were renamed to. GetSubscribers resolves them.
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Namespaces partitions subscribers and events by tenant. Use Namespace() to interact with it.
+	Namespaces map[string]*Subscribe `json:"namespaces,omitempty"`
+	// parent is the Subscribe this namespace belongs to; nil for the root database.
+	parent *Subscribe
+	// namespace is this namespace's name in the parent.
+	namespace string
+	// detached is set once this namespace is removed from its parent. Its changes are no longer persisted.
+	detached atomic.Bool
+	// hookMu protects the change consumers and case folding policy below.
+	// It may be acquired while holding mu, never the reverse.
+	hookMu sync.RWMutex
+	// journal appends every change to a log file when enabled.
+	journal *journal
+	// store persists the database in place of the state file, if not nil.
+	store Store
+	// log receives structured records. nil discards them.
+	log *slog.Logger
+	// tracer starts spans around database operations. nil disables tracing.
+	tracer Tracer
+	// metrics counts calls when enabled.
+	metrics *metrics
+	// audit records GetSubscribers evaluations when enabled.
+	audit *auditLog
+	// logs holds log records until they are handed to the logger. Only used on the root database.
+	logs logQueue
+	// foldCase makes every event name case-insensitive. Only used on the root database.
+	foldCase bool
+	// digestMu protects Digests. It may be acquired while holding mu, never the reverse.
+	digestMu sync.Mutex
+	// occurrenceLimit is the number of Occurrences kept. See SetOccurrenceLimit.
+	occurrenceLimit int
+	// escalationMu protects Escalations, Occurrences and occurrenceLimit.
+	// It may be acquired while holding mu, never the reverse.
+	escalationMu sync.Mutex
+	// fileMu serializes reads and writes of the state file, or saves to the store, and protects stateSum.
+	fileMu sync.Mutex
+	// storeMu protects storeSaving, storePending and storeErr. It may be acquired while holding mu, never the reverse.
+	storeMu sync.Mutex
+	// storeSaving is true while the store saves a snapshot. Changes are held in storePending
+	// meanwhile, and written when the save finishes, so the save cannot erase them.
+	storeSaving  bool
+	storePending []*Change
+	// storeErr is the first failed store write since the last successful save.
+	storeErr error
+	// stateSum is the hash of the state file contents this instance last read or wrote.
+	stateSum [sha256.Size]byte
+}
+
+// Change describes a single mutation to the database.
+// These are written to the journal, or a Store, and replayed on load.
+type Change struct {
+	// Op is one of the Op constants, and decides which other fields are set.
+	Op string `json:"op"`
+	// Namespace is the namespace changed, or empty for the root database.
+	Namespace string `json:"namespace,omitempty"`
+	// Group is set when a group, or its Events, changed.
+	Group string `json:"group,omitempty"`
+	// Sub is set when a subscriber, or its Events, changed. Neither Group nor Sub
+	// are set when the database's Events changed.
+	Sub     *SubscriberRef `json:"sub,omitempty"`
+	Admin   bool           `json:"isAdmin,omitempty"`
+	Ignored bool           `json:"ignored,omitempty"`
+	Event   string         `json:"event,omitempty"`
+	// To is the new name of a renamed event, or the event an alias points to.
+	To    string    `json:"to,omitempty"`
+	Pause time.Time `json:"pause,omitzero"`
+	// Kind is the rule type (D, I, S or T) and Rule its name. An OpRuleDel without a Kind deletes every type.
+	Kind string `json:"kind,omitempty"`
+	Rule string `json:"rule,omitempty"`
+	// Rules are a new subscription's rules, or hold the rule value set by OpRuleSet.
+	Rules    *Rules            `json:"rules,omitempty"`
+	Def      *EventDefinition  `json:"def,omitempty"`
+	Levels   []string          `json:"levels,omitempty"`
+	Digest   *Digest           `json:"digest,omitempty"`
+	Escalate *Escalation       `json:"escalate,omitempty"`
+	Record   *OccurrenceRecord `json:"record,omitempty"`
+}
+
+// SubscriberRef identifies a subscriber. When ID is not 0 it matches a subscriber
+// by ID and API like CreateSubWithID, otherwise by Contact and API like CreateSub.
+type SubscriberRef struct {
+	ID      int64  `json:"id,omitempty"`
+	Contact string `json:"contact"`
+	API     string `json:"api"`
 }
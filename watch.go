@@ -0,0 +1,98 @@
+package subscribe
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+)
+
+/***********************
+ *    Watch Methods    *
+ ***********************/
+
+// DefaultWatchInterval is how often StateFileWatch polls when no interval is provided.
+const DefaultWatchInterval = 5 * time.Second
+
+// StateFileWatch polls the state file and calls StateFileLoad when another process or
+// an operator changes it. Changes are detected by modification time and size, then
+// confirmed with a content hash, so writes made by this instance's StateFileSave are
+// ignored. If a reload fails, the in-memory database is kept and the error is passed
+// to onError (which may be nil). Call the returned function to stop watching.
+// Note that a reload replaces every *Subscriber, so do not hold on to them.
+func (s *Subscribe) StateFileWatch(interval time.Duration, onError func(error)) func() {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	var (
+		done      = make(chan struct{})
+		stopOnce  sync.Once
+		waitGroup sync.WaitGroup
+	)
+
+	waitGroup.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last os.FileInfo
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			if !s.stateFileChanged(&last) {
+				continue
+			}
+
+			if err := s.StateFileLoad(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	})
+
+	return func() {
+		stopOnce.Do(func() { close(done) })
+		waitGroup.Wait()
+	}
+}
+
+// stateFileChanged returns true if the state file no longer matches what this instance
+// last read or wrote. last holds the file info from the previous poll.
+func (s *Subscribe) stateFileChanged(last *os.FileInfo) bool {
+	s = s.root()
+
+	s.mu.RLock()
+	stateFile := s.stateFile
+	s.mu.RUnlock()
+
+	if stateFile == "" {
+		return false
+	}
+
+	info, err := os.Stat(stateFile)
+	if err != nil {
+		// Missing or unreadable; wait for it to come back.
+		return false
+	}
+
+	if *last != nil && info.ModTime().Equal((*last).ModTime()) && info.Size() == (*last).Size() {
+		return false
+	}
+
+	*last = info
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	// #nosec G304 -- state file path is user-configured on purpose.
+	buf, err := os.ReadFile(stateFile)
+	if err != nil {
+		return false
+	}
+
+	return sha256.Sum256(buf) != s.stateSum
+}
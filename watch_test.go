@@ -0,0 +1,64 @@
+package subscribe
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateFileWatch(t *testing.T) {
+	t.Parallel()
+
+	stateFile := filepath.Join(t.TempDir(), "watch.json")
+	sub, err := GetDB(stateFile)
+	require.NoError(t, err)
+
+	var errCount atomic.Int32
+
+	stop := sub.StateFileWatch(5*time.Millisecond, func(error) { errCount.Add(1) })
+	defer stop()
+
+	// Our own saves must not trigger a reload.
+	mine := sub.CreateSub("mine", "api", false, false)
+	require.NoError(t, sub.StateFileSave())
+	time.Sleep(50 * time.Millisecond)
+
+	got, err := sub.GetSubscriber("mine", "api")
+	require.NoError(t, err)
+	assert.Same(t, mine, got, "a save by this instance must not reload the database")
+
+	// External changes are loaded.
+	external := `{"enabledApis":["api"],"events":{"eventsMap":{}},"subscribers":[{"api":"api","contact":"theirs"}]}`
+	require.NoError(t, os.WriteFile(stateFile, []byte(external), 0o600))
+	assert.Eventually(t, func() bool {
+		_, err := sub.GetSubscriber("theirs", "api")
+		return err == nil
+	}, time.Second, 5*time.Millisecond, "an external change must be reloaded")
+
+	// A broken file is reported and the in-memory state is kept.
+	require.NoError(t, os.WriteFile(stateFile, []byte("{broken"), 0o600))
+	assert.Eventually(t, func() bool { return errCount.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	_, err = sub.GetSubscriber("theirs", "api")
+	require.NoError(t, err, "a failed reload must not discard the database")
+
+	stop()
+	stop()
+}
+
+func TestStateFileWatchNoFile(t *testing.T) {
+	t.Parallel()
+
+	sub, err := GetDB("")
+	require.NoError(t, err)
+
+	var last os.FileInfo
+
+	assert.False(t, sub.stateFileChanged(&last))
+	sub.StateFileWatch(0, nil)()
+}
@@ -0,0 +1,170 @@
+package subscribe
+
+import (
+	"strings"
+)
+
+/**************************
+ *    Wildcard Methods    *
+ **************************/
+
+// Hierarchical event names use dots between levels, like "camera.front.motion".
+// A subscription may be a pattern instead of an event name. In a pattern, a level
+// that is exactly "*" matches any one level, and a level that is exactly "#"
+// matches zero or more levels. "camera.*" matches "camera.front" but not
+// "camera.front.motion"; "camera.#" matches both, and "camera" itself.
+const (
+	EventSeparator = "."
+	WildcardOne    = "*"
+	WildcardMany   = "#"
+)
+
+// Match returns the subscription that applies to an event: the event itself if it
+// exists, otherwise the most specific pattern that matches it. A pattern with more
+// literal levels is more specific, then one with more "*" levels. Returns an empty
+// string if nothing matches. IsPaused, PauseTime and the RuleGet methods use this.
+// With case folding enabled, literal levels match regardless of case.
+func (e *Events) Match(event string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	name, _ := e.matchLocked(event)
+
+	return name
+}
+
+// matchLocked returns the name and rules of the subscription that applies to an event.
+// Call with mu held.
+func (e *Events) matchLocked(event string) (string, *Rules) {
+	if key := e.keyLocked(event); e.Map[key] != nil {
+		return key, e.Map[key]
+	}
+
+	patterns := e.patternsLocked()
+	if len(patterns) == 0 {
+		return "", nil
+	}
+
+	if e.fold {
+		event = strings.ToLower(event)
+	}
+
+	var (
+		best      string
+		bestScore patternScore
+	)
+
+	for _, name := range patterns {
+		pattern := name
+		if e.fold {
+			pattern = strings.ToLower(name)
+		}
+
+		if !matchPattern(pattern, event) {
+			continue
+		}
+
+		score := scorePattern(name)
+		if best == "" || score.beats(bestScore) || (score == bestScore && name < best) {
+			best, bestScore = name, score
+		}
+	}
+
+	return best, e.Map[best]
+}
+
+// patternsLocked returns the names in Map that are patterns. They are listed again after
+// names are added or removed. Call with mu held; readers racing to list them store the same list.
+func (e *Events) patternsLocked() []string {
+	if cached := e.patterns.Load(); cached != nil {
+		return *cached
+	}
+
+	patterns := []string{}
+
+	for name := range e.Map {
+		if isPattern(name) {
+			patterns = append(patterns, name)
+		}
+	}
+
+	e.patterns.Store(&patterns)
+
+	return patterns
+}
+
+// patternScore ranks how specific a pattern is.
+type patternScore struct {
+	literal int
+	one     int
+}
+
+func (p patternScore) beats(other patternScore) bool {
+	if p.literal != other.literal {
+		return p.literal > other.literal
+	}
+
+	return p.one > other.one
+}
+
+func scorePattern(pattern string) patternScore {
+	score := patternScore{}
+
+	for level := range strings.SplitSeq(pattern, EventSeparator) {
+		switch level {
+		case WildcardMany:
+		case WildcardOne:
+			score.one++
+		default:
+			score.literal++
+		}
+	}
+
+	return score
+}
+
+// isPattern returns true if a subscription name has a wildcard level.
+func isPattern(name string) bool {
+	for level := range strings.SplitSeq(name, EventSeparator) {
+		if level == WildcardOne || level == WildcardMany {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern returns true if the pattern matches the event name.
+func matchPattern(pattern, event string) bool {
+	return matchLevels(strings.Split(pattern, EventSeparator), strings.Split(event, EventSeparator))
+}
+
+// matchLevels matches pattern levels against event levels. When a level after a "#" does not
+// match, the "#" takes one more level and matching resumes after it. Only the last "#" needs
+// to be retried, so this takes at most len(pattern) * len(event) steps.
+func matchLevels(pattern, event []string) bool {
+	pIdx, eIdx := 0, 0
+	lastMany, manyEnd := -1, 0
+
+	for eIdx < len(event) {
+		switch {
+		case pIdx < len(pattern) && pattern[pIdx] == WildcardMany:
+			lastMany, manyEnd = pIdx, eIdx
+			pIdx++
+		case pIdx < len(pattern) && (pattern[pIdx] == WildcardOne || pattern[pIdx] == event[eIdx]):
+			pIdx++
+			eIdx++
+		case lastMany >= 0:
+			manyEnd++
+			pIdx, eIdx = lastMany+1, manyEnd
+		default:
+			return false
+		}
+	}
+
+	for pIdx < len(pattern) && pattern[pIdx] == WildcardMany {
+		pIdx++
+	}
+
+	return pIdx == len(pattern)
+}
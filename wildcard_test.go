@@ -0,0 +1,98 @@
+package subscribe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		event   string
+		match   bool
+	}{
+		{"camera.*", "camera.front", true},
+		{"camera.*", "camera.front.motion", false},
+		{"camera.*", "camera", false},
+		{"camera.#", "camera", true},
+		{"camera.#", "camera.front", true},
+		{"camera.#", "camera.front.motion", true},
+		{"camera.#", "doorbell.front", false},
+		{"*.front.*", "camera.front.motion", true},
+		{"*.front.*", "camera.back.motion", false},
+		{"#.motion", "camera.front.motion", true},
+		{"#.motion", "motion", true},
+		{"camera.#.motion", "camera.motion", true},
+		{"camera.#.motion", "camera.front.side.motion", true},
+		{"camera.#.motion", "camera.front.sound", false},
+		{"#", "anything.at.all", true},
+		{"a.#.b.#.c", "a.x.b.y.b.z.c", true},
+		{"a.#.b.#.c", "a.x.b.y.c.z", false},
+		{"#.*.#", "one", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, matchPattern(test.pattern, test.event), "%s ~ %s", test.pattern, test.event)
+	}
+
+	// Every "#" may take any number of levels; this must not try every combination.
+	pattern := strings.Repeat("#.", 20) + "missing"
+	event := strings.TrimSuffix(strings.Repeat("level.", 200), ".")
+	assert.False(t, matchPattern(pattern, event))
+
+	assert.True(t, isPattern("camera.*"))
+	assert.False(t, isPattern("camera.mo*"), "a wildcard must be a whole level")
+}
+
+func TestEventsMatch(t *testing.T) {
+	t.Parallel()
+
+	events := &Events{Map: make(map[string]*Rules)}
+	for _, name := range []string{"#", "camera.#", "camera.*.motion", "camera.front.motion"} {
+		require.NoError(t, events.New(name, nil))
+	}
+
+	assert.Equal(t, "camera.front.motion", events.Match("camera.front.motion"), "an exact match wins")
+	assert.Equal(t, "camera.*.motion", events.Match("camera.back.motion"))
+	assert.Equal(t, "camera.#", events.Match("camera.back.sound"))
+	assert.Equal(t, "#", events.Match("doorbell"))
+
+	events.Remove("#")
+	assert.Empty(t, events.Match("doorbell"))
+
+	require.NoError(t, events.New("doorbell.#", nil))
+	assert.Equal(t, "doorbell.#", events.Match("doorbell"), "patterns added after a lookup must match")
+}
+
+func TestWildcardSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	assertions := assert.New(t)
+	sub := &Subscribe{Events: new(Events)}
+
+	everything := sub.CreateSub("everything", "api", false, false)
+	require.NoError(t, everything.Subscribe("camera.#"))
+	everything.Events.RuleSetI("camera.#", "priority", 1)
+
+	quiet := sub.CreateSub("quiet", "api", false, false)
+	require.NoError(t, quiet.Subscribe("camera.*"))
+	require.NoError(t, quiet.Subscribe("camera.front"))
+	require.NoError(t, quiet.Events.Pause("camera.front", time.Hour))
+
+	assertions.Equal([]*Subscriber{everything, quiet}, sub.GetSubscribers("camera.back"))
+	assertions.Equal([]*Subscriber{everything}, sub.GetSubscribers("camera.front"),
+		"the exact subscription's pause must take precedence over the pattern")
+	assertions.Equal([]*Subscriber{everything}, sub.GetSubscribers("camera.front.motion"))
+	assertions.Empty(sub.GetSubscribers("doorbell"))
+
+	priority, ok := everything.Events.RuleGetI("camera.front.motion", "priority")
+	assertions.True(ok, "rules must come from the matching pattern")
+	assertions.Equal(1, priority)
+	assertions.True(quiet.Events.PauseTime("camera.front").After(time.Now()))
+}